@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	sqlorig "database/sql"
 	"fmt"
+	"sync"
 	"time"
 
 	sql "github.com/Shopify/ghostferry/sqlwrapper"
@@ -37,6 +38,13 @@ type BinlogStreamer struct {
 
 	stopRequested bool
 
+	// Closed once Run returns. The error that stopped the streamer, if any, is
+	// recorded in stopErr, guarded by stopMutex.
+	stopped     chan struct{}
+	stoppedOnce sync.Once
+	stopErr     error
+	stopMutex   sync.Mutex
+
 	logger         *logrus.Entry
 	eventListeners []func([]DMLEvent) error
 }
@@ -129,6 +137,7 @@ func (s *BinlogStreamer) Run() {
 			"lastStreamedBinlogPosition": s.lastStreamedBinlogPosition,
 		}).Info("exiting binlog streamer")
 		s.binlogSyncer.Close()
+		close(s.stoppedChannel())
 	}()
 
 	var query []byte
@@ -156,7 +165,7 @@ func (s *BinlogStreamer) Run() {
 		})
 
 		if err != nil {
-			s.ErrorHandler.Fatal("binlog_streamer", err)
+			s.fatal(err)
 		}
 
 		if timedOut {
@@ -224,7 +233,7 @@ func (s *BinlogStreamer) Run() {
 			err = s.handleRowsEvent(ev, query)
 			if err != nil {
 				s.logger.WithError(err).Error("failed to handle rows event")
-				s.ErrorHandler.Fatal("binlog_streamer", err)
+				s.fatal(err)
 			}
 		case *replication.XIDEvent, *replication.GTIDEvent:
 			// With regards to DMLs, we see (at least) the following sequence
@@ -267,6 +276,39 @@ func (s *BinlogStreamer) Run() {
 	}
 }
 
+// Returns a channel that is closed once the streamer has stopped, either
+// after FlushAndStop or because of an error, see Err.
+func (s *BinlogStreamer) Stopped() <-chan struct{} {
+	return s.stoppedChannel()
+}
+
+// Returns the error that stopped the streamer, or nil if it was not stopped
+// by an error.
+func (s *BinlogStreamer) Err() error {
+	s.stopMutex.Lock()
+	defer s.stopMutex.Unlock()
+
+	return s.stopErr
+}
+
+func (s *BinlogStreamer) stoppedChannel() chan struct{} {
+	s.stoppedOnce.Do(func() {
+		s.stopped = make(chan struct{})
+	})
+
+	return s.stopped
+}
+
+func (s *BinlogStreamer) fatal(err error) {
+	s.stopMutex.Lock()
+	if s.stopErr == nil {
+		s.stopErr = err
+	}
+	s.stopMutex.Unlock()
+
+	s.ErrorHandler.Fatal("binlog_streamer", err)
+}
+
 func (s *BinlogStreamer) AddEventListener(listener func([]DMLEvent) error) {
 	s.eventListeners = append(s.eventListeners, listener)
 }
@@ -293,7 +335,7 @@ func (s *BinlogStreamer) FlushAndStop() {
 	})
 
 	if err != nil {
-		s.ErrorHandler.Fatal("binlog_streamer", err)
+		s.fatal(err)
 	}
 	s.logger.WithField("stop_at_position", s.stopAtBinlogPosition).Info("current stop binlog position was recorded")
 
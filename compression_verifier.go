@@ -81,6 +81,10 @@ func (c *CompressionVerifier) GetCompressedHashes(db *sql.DB, schema, table, pag
 			return nil, err
 		}
 
+		if rowData[0] == nil {
+			return nil, fmt.Errorf("NULL primary key encountered in table %s.%s", schema, table)
+		}
+
 		paginationKey, err := strconv.ParseUint(string(rowData[0]), 10, 64)
 		if err != nil {
 			return nil, err
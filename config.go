@@ -3,9 +3,12 @@ package ghostferry
 import (
 	"crypto/tls"
 	"crypto/x509"
+	sqlorig "database/sql"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"regexp"
+	"strings"
 	"time"
 
 	sql "github.com/Shopify/ghostferry/sqlwrapper"
@@ -202,6 +205,428 @@ type IterativeVerifierConfig struct {
 	// This is in the format of table_name -> [list of column names]
 	IgnoredColumns map[string][]string
 
+	// Map of table name to the column used to identify rows during
+	// verification instead of the pagination key. The column must hold
+	// unique, unsigned integer values.
+	//
+	// Optional: defaults to the pagination key of each table.
+	VerificationKeyColumns map[string]string
+
+	// If enabled, a single aggregate checksum per batch is compared before
+	// comparing the fingerprints of the individual rows. Batches whose
+	// aggregate checksums match are not fingerprinted row by row.
+	//
+	// Optional: defaults to false
+	BatchChecksumShortCircuit bool
+
+	// Compare the rows of each table before cutover by windows of this many
+	// rows, with a single aggregate checksum per window, and only fingerprint
+	// the rows of the windows whose checksums differ.
+	//
+	// Optional: defaults to 0, which verifies all the tables row by row
+	WindowChecksumSize uint64
+
+	// The fraction of the time, between 0 and 1, during which the tables are
+	// verified before cutover. The verification sleeps after each batch in
+	// proportion to the time spent on the batch.
+	//
+	// Optional: defaults to 0, which does not sleep between batches
+	DutyCycle float64
+
+	// The time after which the verification of a table before cutover only
+	// verifies a sample of its remaining rows, in the format of
+	// time.ParseDuration. Such tables are reported as partially verified.
+	//
+	// Optional: defaults to verifying all the rows of the tables
+	TableTimeBudget string
+
+	// The fraction of the remaining rows verified once a table exceeds its
+	// TableTimeBudget, between 0 and 1.
+	//
+	// Optional: defaults to 0.01
+	TableTimeBudgetSampleRate float64
+
+	// The time after which processing a batch of rows before cutover fails
+	// the verification rather than hanging, in the format of
+	// time.ParseDuration.
+	//
+	// Optional: defaults to not timing out batches
+	BatchTimeout string
+
+	// Fail the verification if a table yields no rows while information_schema
+	// estimates it to be non-empty. By default only a warning is logged.
+	//
+	// Optional: defaults to false
+	FailOnUnexpectedlyEmptyTables bool
+
+	// The transaction isolation level of the fingerprint queries on both the
+	// source and the target. One of "READ UNCOMMITTED", "READ COMMITTED",
+	// "REPEATABLE READ" or "SERIALIZABLE".
+	//
+	// Optional: defaults to the session default of each server
+	ReadIsolationLevel string
+
+	// The maximum number of paginationKeys in a single fingerprint query.
+	// Larger batches are split into multiple queries.
+	//
+	// Optional: defaults to 0, which does not split batches
+	MaxInClauseSize int
+
+	// Map of table name => column name => value that NULL in the column is
+	// considered equivalent to during verification.
+	//
+	// Optional: defaults to NULL only being equal to NULL
+	NullEquivalentValues map[string]map[string]string
+
+	// Send the fingerprint queries using the plain text protocol instead of
+	// preparing them, for proxies without prepared statement support.
+	//
+	// Optional: defaults to false
+	DisablePreparedStatements bool
+
+	// The maximum number of statements prepared by the verifier that are
+	// open at the same time on each of the source and the target, to stay
+	// below the max_prepared_stmt_count of the servers. Batches wait for a
+	// statement to be closed instead of failing.
+	//
+	// Optional: defaults to 0, which does not bound the prepared statements
+	MaxPreparedStatementsPerDB int
+
+	// Warn when a fingerprint query is estimated by EXPLAIN to examine more
+	// than this many times the number of rows it fingerprints, which usually
+	// points at a missing index on the target. See
+	// IterativeVerifier.ExaminedRowsWarningRatio.
+	//
+	// Optional: defaults to 0, which does not explain the queries
+	ExaminedRowsWarningRatio float64
+
+	// Only fingerprint the rows with the largest paginationKeys of each table
+	// before cutover, as a fast check that the tail of the tables was copied.
+	//
+	// Optional: defaults to 0, which fingerprints all the rows
+	VerifyTailRows int
+
+	// Fingerprint the rows of each table from the largest paginationKey
+	// downward before cutover, so that mismatches in the most recently
+	// inserted rows are found first. Not supported with a CopyFilter.
+	//
+	// Optional: defaults to false
+	VerifyDescending bool
+
+	// Fail the verification with a "target unhealthy" error, instead of
+	// retrying each batch, once the fraction of the recent queries to the
+	// target that failed exceeds this rate, between 0 and 1.
+	//
+	// Optional: defaults to 0, which disables the circuit breaker
+	TargetCircuitBreakerMaxErrorRate float64
+
+	// The number of recent queries to the target over which the error rate
+	// of TargetCircuitBreakerMaxErrorRate is computed.
+	//
+	// Optional: defaults to 100
+	TargetCircuitBreakerWindow int
+
+	// Whether the reverification of the rows continues after a batch errors
+	// or finds mismatched rows: "abort_on_failure" aborts at the first such
+	// batch, "abort_on_error" only aborts on errors and "best_effort" always
+	// continues. The mismatches of all the reverified batches are reported.
+	//
+	// Optional: defaults to "abort_on_failure"
+	ReverifyFailurePolicy string
+
+	// Mismatched rows modified on the source within this duration, according
+	// to their ModificationTimestampColumns, are compared again after waiting
+	// for this duration, for targets that are replicas lagging the source.
+	//
+	// Optional: defaults to reporting all mismatches immediately
+	ReplicationLagTolerance string
+
+	// Wait for the target to execute the GTID set executed by the source
+	// before fingerprinting each batch, for targets that are replicas of the
+	// source.
+	//
+	// Optional: defaults to false
+	WaitForSourceGtid bool
+
+	// The time the target is waited for with WaitForSourceGtid before failing
+	// the verification, in the format of time.ParseDuration.
+	//
+	// Optional: defaults to 1m
+	GtidWaitTimeout string
+
+	// Map of table name => column holding the time of the last modification
+	// of each row, used with the ReplicationLagTolerance.
+	//
+	// Optional: defaults to no modification timestamp columns
+	ModificationTimestampColumns map[string]string
+
+	// Map of table name => SQL predicate, such as "schema_version = 3", that
+	// the rows at the current version of a rolling migration match on the
+	// source. The mismatched rows at older versions are counted separately
+	// rather than reported as mismatches.
+	//
+	// Optional: defaults to reporting the mismatches of all the rows
+	CurrentVersionPredicates map[string]string
+
+	// Prefixes and suffixes of the names of all the databases and tables on
+	// the target, such as "prod_". The DatabaseRewrites and TableRewrites
+	// take precedence over these.
+	//
+	// Optional: defaults to no prefixes or suffixes
+	DatabaseNamePrefix string
+	DatabaseNameSuffix string
+	TableNamePrefix    string
+	TableNameSuffix    string
+
+	// Map of table name => index hint added to the fingerprint queries of the
+	// table, such as "FORCE INDEX (PRIMARY)". Only USE, FORCE and IGNORE
+	// INDEX hints are accepted.
+	//
+	// Optional: defaults to no index hints
+	IndexHints map[string]string
+
+	// Comments inserted after the SELECT keyword of the verification reads of
+	// the source and the target respectively, such as
+	// "/*+ read_from_replica */", to route them to a replica. Each must be a
+	// single /* ... */ comment.
+	//
+	// Optional: defaults to no hints
+	SourceReadHint string
+	TargetReadHint string
+
+	// If set, the rows to reverify that were deleted on both sides are pruned
+	// with a cheap existence query before the rows are fingerprinted.
+	//
+	// Optional: defaults to false
+	PruneDeletedRows bool
+
+	// If enabled, the rows of every batch are fingerprinted twice on the
+	// source, and the rows whose fingerprints differ between the two reads
+	// are reported as changing on the source rather than blamed on the
+	// target.
+	//
+	// Optional: defaults to false
+	SourceSelfConsistencyCheck bool
+
+	// The number of columns whose hashes are grouped together in the row
+	// fingerprints of very wide tables, see
+	// IterativeVerifier.FingerprintColumnGroupSize.
+	//
+	// Optional: defaults to 0, which does not group the columns
+	FingerprintColumnGroupSize int
+
+	// The SQL function hashing the rows into their fingerprints, MD5 or
+	// CRC32. CRC32 is much cheaper on wide rows, but as its hashes only have
+	// 32 bits, a mismatched row has a one in 2^32 chance of going unnoticed.
+	//
+	// Optional: defaults to MD5
+	FingerprintHashFunction string
+
+	// The alias of the row fingerprints in the fingerprint queries, see
+	// IterativeVerifier.RowFingerprintAlias.
+	//
+	// Optional: defaults to row_fingerprint
+	RowFingerprintAlias string
+
+	// The format of the row fingerprints, 1 or 2, see
+	// IterativeVerifier.FingerprintFormat. Format 2 tells NULL apart from the
+	// string 'NULL'.
+	//
+	// Optional: defaults to 1
+	FingerprintFormat int
+
+	// Path of a file to which the queries, the hashes and the mismatches of
+	// every compared batch are appended as lines of JSON, to reproduce a
+	// verification offline. This records the hashes of every row and is only
+	// meant for debugging.
+	//
+	// Optional: defaults to not recording the fingerprints
+	FingerprintRecordFile string
+
+	// If set, the tables are verified from the largest to the smallest by
+	// their estimated number of rows, which shortens the verification of
+	// databases with a few large tables among many small ones.
+	//
+	// Optional: defaults to false
+	VerifyLargestTablesFirst bool
+
+	// Prefix of the tags of the logs of the verifier, such as "tenant_1.", to
+	// tell apart the logs of multiple verifiers.
+	//
+	// Optional: defaults to no prefix
+	LogTagPrefix string
+
+	// Map of table name => SQL predicate, such as "status = 'active'", that
+	// the verified rows of the table must match. The predicate is inserted in
+	// the verification queries as is: it must only be set by the operator.
+	// It must be a single expression, without statement separators or
+	// comments.
+	//
+	// Optional: defaults to verifying all the rows
+	VerifyWhere map[string]string
+
+	// Map of table name => SQL predicate, such as "origin = 'orders_eu'",
+	// selecting on the target the rows originating from the table, for
+	// source tables merged into one target table by the TableRewrites. The
+	// same restrictions as for the VerifyWhere apply.
+	//
+	// Optional: defaults to comparing against all the rows of the target
+	MergedTablePredicates map[string]string
+
+	// The number of verifiers verifying disjoint subsets of the rows in
+	// parallel, and the index of the subset verified by this verifier, from
+	// 0 to ShardCount - 1. A row belongs to the subset of its paginationKey,
+	// or of its VerificationKeyColumns, modulo ShardCount.
+	//
+	// Optional: defaults to verifying all the rows
+	ShardIndex int
+	ShardCount int
+
+	// Path of a file in which a signature of each table verified to match is
+	// persisted across runs, so that the unchanged tables are not verified
+	// again. Every verified table must be listed in the
+	// ModificationTimestampColumns. See IterativeVerifier.TableSignatureFile.
+	//
+	// Optional: defaults to verifying all the tables on every run
+	TableSignatureFile string
+
+	// Map of table name => target column name => MySQL expression over the
+	// source columns computing the expected value of a column that only
+	// exists on the target, such as "CONCAT(`first_name`, ' ', `last_name`)".
+	//
+	// Optional: defaults to no computed columns
+	ComputedColumns map[string]map[string]string
+
+	// Map of table name => column name => MySQL expression over the source
+	// columns computing the value stored in the column on the target, for
+	// migrations transforming the data, such as "AES_ENCRYPT(`ssn`, 'key')".
+	//
+	// Optional: defaults to no transformations
+	ColumnTransformations map[string]map[string]string
+
+	// Map of table name => whether rows that only exist on the target, such
+	// as rows inserted by triggers, are expected and not mismatches.
+	//
+	// Optional: defaults to reporting target-only rows as mismatches
+	TargetOnlyRowsExpected map[string]bool
+
+	// If enabled, rows that only exist on the target are not mismatches for
+	// any table, such as when the target is shared with other sources.
+	//
+	// Optional: defaults to false
+	TargetIsSuperset bool
+
+	// Map of table name => columns whose values are lowercased before being
+	// fingerprinted, so that intentional case folding is not a mismatch.
+	//
+	// Optional: defaults to comparing all columns case-sensitively
+	CaseInsensitiveColumns map[string][]string
+
+	// Map of table name => columns widened by the migration, such as from INT
+	// to BIGINT, whose values are normalized before being fingerprinted so
+	// that the widened values hash the same, see
+	// IterativeVerifier.WidenedColumns.
+	//
+	// Optional: defaults to no widened columns
+	WidenedColumns map[string][]string
+
+	// Map of table name => columns whose values are compressed with MySQL's
+	// COMPRESS() on the target but not on the source. These columns are
+	// compared by their decompressed content.
+	//
+	// Optional: defaults to no compressed columns
+	TargetMysqlCompressedColumns map[string][]string
+
+	// Path of a file to which a JSON snapshot of the verification progress is
+	// written every ProgressSnapshotInterval, in the format of
+	// time.ParseDuration.
+	//
+	// Optional: defaults to not writing snapshots
+	ProgressSnapshotFile     string
+	ProgressSnapshotInterval string
+
+	// Map of table name => column name => severity of a difference in the
+	// column, one of "low", "medium" or "high". Mismatches of a severity
+	// below MinimumFailingSeverity do not fail the verification.
+	//
+	// Optional: defaults to "high" for all columns
+	ColumnSeverities       map[string]map[string]string
+	MinimumFailingSeverity string
+
+	// If enabled, the column defaults of the tables are compared between the
+	// source and the target, and a difference fails the verification.
+	//
+	// Optional: defaults to false
+	CompareColumnDefaults bool
+
+	// If enabled, the ROW START and ROW END columns of the system-versioned
+	// tables of MariaDB are excluded from the fingerprints, see
+	// IterativeVerifier.ExcludeSystemVersioningColumns.
+	//
+	// Optional: defaults to false
+	ExcludeSystemVersioningColumns bool
+
+	// If enabled, the tables of the target are checked for rows whose
+	// paginationKeys are greater than the largest one of the source, which
+	// fail the verification.
+	//
+	// Optional: defaults to false
+	CheckTargetMaxPaginationKey bool
+
+	// Map of table name => number of paginationKeys above the largest one of
+	// the source within which the rows of the target are tolerated by
+	// CheckTargetMaxPaginationKey.
+	//
+	// Optional: defaults to no tolerance
+	MaxPaginationKeyTolerances map[string]uint64
+
+	// If enabled, the mismatched rows are copied again from the source to
+	// the target and verified again. The rows that match after the repair do
+	// not fail the verification.
+	//
+	// Optional: defaults to false
+	EnableRepair bool
+
+	// If enabled along with EnableRepair, the rows that would be repaired are
+	// only logged.
+	//
+	// Optional: defaults to false
+	RepairDryRun bool
+
+	// Map of table name => aggregates of its columns compared between the
+	// source and the target after the rows are verified. Each aggregate is
+	// one of SUM, MIN, MAX or COUNT of a column, such as "SUM(amount)" or
+	// "COUNT(*)".
+	//
+	// Optional: defaults to no aggregates
+	Aggregates map[string][]string
+
+	// If enabled, the rows of the tables with Aggregates are not verified
+	// individually and only their aggregates are compared.
+	//
+	// Optional: defaults to false
+	AggregatesOnly bool
+
+	// Map of table name => columns of low cardinality, such as flags or small
+	// enums, of which the number of rows by value is compared between the
+	// source and the target after the rows are verified.
+	//
+	// Optional: defaults to no columns
+	DistributionColumns map[string][]string
+
+	// If enabled, the mismatches report the differing columns of the rows
+	// and the byte offset at which their values first differ.
+	//
+	// Optional: defaults to false
+	ReportDivergenceOffsets bool
+
+	// Map of table name => path of a CSV file of paginationKey,fingerprint
+	// lines of the target rows. If set, the target fingerprints are read from
+	// these files instead of being queried from the target.
+	//
+	// Optional: defaults to querying the target
+	TargetFingerprintFiles map[string]string
+
 	// The number of concurrent verifiers. Note that a single table can only be
 	// assigned to one goroutine and currently multiple goroutines per table
 	// is not supported.
@@ -229,6 +654,11 @@ type IterativeVerifierConfig struct {
 	// If this is specified, ColumnCompressionConfig should also be filled out in
 	// the main Config.
 	TableColumnCompression TableColumnCompressionConfig
+
+	// The connections and state needed by the IterativeVerifier built by
+	// NewIterativeVerifier. These are set by the caller, such as
+	// Ferry.NewIterativeVerifier, rather than loaded with the config.
+	Dependencies IterativeVerifierDependencies `json:"-"`
 }
 
 func (c *IterativeVerifierConfig) Validate() error {
@@ -239,6 +669,171 @@ func (c *IterativeVerifierConfig) Validate() error {
 		}
 	}
 
+	if _, err := parseIsolationLevel(c.ReadIsolationLevel); err != nil {
+		return err
+	}
+
+	if c.ProgressSnapshotInterval != "" {
+		_, err := time.ParseDuration(c.ProgressSnapshotInterval)
+		if err != nil {
+			return err
+		}
+	}
+
+	for table, columns := range c.ColumnSeverities {
+		for column, severity := range columns {
+			if _, err := ParseMismatchSeverity(severity); err != nil {
+				return fmt.Errorf("invalid severity for column %s of table %s: %v", column, table, err)
+			}
+		}
+	}
+
+	if c.MinimumFailingSeverity != "" {
+		if _, err := ParseMismatchSeverity(c.MinimumFailingSeverity); err != nil {
+			return fmt.Errorf("invalid MinimumFailingSeverity: %v", err)
+		}
+	}
+
+	for table, indexHint := range c.IndexHints {
+		if !indexHintRegexp.MatchString(indexHint) {
+			return fmt.Errorf("invalid index hint for table %s: %s", table, indexHint)
+		}
+	}
+
+	for table, transformations := range c.ColumnTransformations {
+		for column, expression := range transformations {
+			if err := validateWherePredicate(expression); err != nil {
+				return fmt.Errorf("invalid ColumnTransformations for column %s.%s: %v", table, column, err)
+			}
+		}
+	}
+
+	if err := validateReadHint(c.SourceReadHint); err != nil {
+		return fmt.Errorf("invalid SourceReadHint: %v", err)
+	}
+
+	if err := validateReadHint(c.TargetReadHint); err != nil {
+		return fmt.Errorf("invalid TargetReadHint: %v", err)
+	}
+
+	if c.ShardCount < 0 {
+		return fmt.Errorf("ShardCount must not be negative, not %d", c.ShardCount)
+	}
+
+	if c.ShardCount > 0 && (c.ShardIndex < 0 || c.ShardIndex >= c.ShardCount) {
+		return fmt.Errorf("ShardIndex must be between 0 and ShardCount %d, not %d", c.ShardCount, c.ShardIndex)
+	}
+
+	for table, predicate := range c.VerifyWhere {
+		if err := validateWherePredicate(predicate); err != nil {
+			return fmt.Errorf("invalid VerifyWhere for table %s: %v", table, err)
+		}
+	}
+
+	for table, predicate := range c.MergedTablePredicates {
+		if err := validateWherePredicate(predicate); err != nil {
+			return fmt.Errorf("invalid MergedTablePredicates for table %s: %v", table, err)
+		}
+	}
+
+	for table, predicate := range c.CurrentVersionPredicates {
+		if err := validateWherePredicate(predicate); err != nil {
+			return fmt.Errorf("invalid CurrentVersionPredicates for table %s: %v", table, err)
+		}
+	}
+
+	if c.ReplicationLagTolerance != "" {
+		_, err := time.ParseDuration(c.ReplicationLagTolerance)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.GtidWaitTimeout != "" {
+		gtidWaitTimeout, err := time.ParseDuration(c.GtidWaitTimeout)
+		if err != nil {
+			return err
+		}
+
+		if gtidWaitTimeout < 0 {
+			return fmt.Errorf("GtidWaitTimeout must not be negative, not %v", gtidWaitTimeout)
+		}
+	}
+
+	if _, err := ParseReverifyFailurePolicy(c.ReverifyFailurePolicy); err != nil {
+		return err
+	}
+
+	for _, aggregates := range c.Aggregates {
+		for _, aggregate := range aggregates {
+			if _, err := ParseAggregate(aggregate); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.ExaminedRowsWarningRatio < 0 {
+		return fmt.Errorf("ExaminedRowsWarningRatio must not be negative, not %v", c.ExaminedRowsWarningRatio)
+	}
+
+	if c.MaxPreparedStatementsPerDB < 0 {
+		return fmt.Errorf("MaxPreparedStatementsPerDB must not be negative, not %d", c.MaxPreparedStatementsPerDB)
+	}
+
+	switch FingerprintHashFunction(c.FingerprintHashFunction) {
+	case "", FingerprintHashMD5, FingerprintHashCRC32:
+	default:
+		return fmt.Errorf("FingerprintHashFunction must be MD5 or CRC32, not %s", c.FingerprintHashFunction)
+	}
+
+	switch FingerprintFormat(c.FingerprintFormat) {
+	case 0, FingerprintFormatV1, FingerprintFormatV2:
+	default:
+		return fmt.Errorf("FingerprintFormat must be 1 or 2, not %d", c.FingerprintFormat)
+	}
+
+	if c.RowFingerprintAlias != "" && !rowFingerprintAliasRegexp.MatchString(c.RowFingerprintAlias) {
+		return fmt.Errorf("RowFingerprintAlias must be an identifier of letters, digits and underscores, not %s", c.RowFingerprintAlias)
+	}
+
+	if c.FingerprintColumnGroupSize < 0 {
+		return fmt.Errorf("FingerprintColumnGroupSize must not be negative, not %d", c.FingerprintColumnGroupSize)
+	}
+
+	if c.DutyCycle < 0 || c.DutyCycle > 1 {
+		return fmt.Errorf("DutyCycle must be between 0 and 1, not %v", c.DutyCycle)
+	}
+
+	if c.TableTimeBudget != "" {
+		_, err := time.ParseDuration(c.TableTimeBudget)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.BatchTimeout != "" {
+		_, err := time.ParseDuration(c.BatchTimeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.TableTimeBudgetSampleRate < 0 || c.TableTimeBudgetSampleRate > 1 {
+		return fmt.Errorf("TableTimeBudgetSampleRate must be between 0 and 1, not %v", c.TableTimeBudgetSampleRate)
+	}
+
+	if c.TargetCircuitBreakerMaxErrorRate < 0 || c.TargetCircuitBreakerMaxErrorRate >= 1 {
+		return fmt.Errorf("TargetCircuitBreakerMaxErrorRate must be between 0 and 1, not %v", c.TargetCircuitBreakerMaxErrorRate)
+	}
+
+	if c.TargetCircuitBreakerWindow < 0 {
+		return fmt.Errorf("TargetCircuitBreakerWindow must not be negative, not %d", c.TargetCircuitBreakerWindow)
+	}
+
+	if c.TargetCircuitBreakerWindow == 0 {
+		c.TargetCircuitBreakerWindow = 100
+	}
+
 	if c.Concurrency == 0 {
 		c.Concurrency = 4
 	}
@@ -246,6 +841,94 @@ func (c *IterativeVerifierConfig) Validate() error {
 	return nil
 }
 
+var indexHintRegexp = regexp.MustCompile(`(?i)^(USE|FORCE|IGNORE) (INDEX|KEY) \([A-Za-z0-9_$, ]*\)$`)
+
+// Checks that the predicate is a single expression that can be inserted in a
+// WHERE clause: its quotes and parentheses must be balanced, and it must not
+// contain statement separators or comments.
+func validateWherePredicate(predicate string) error {
+	if strings.TrimSpace(predicate) == "" {
+		return errors.New("predicate must not be empty")
+	}
+
+	depth := 0
+	var quote rune
+	escaped := false
+	previous := ' '
+	for _, char := range predicate {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if char == '\\' && quote != '`' {
+				escaped = true
+			} else if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"' || char == '`':
+			quote = char
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+			if depth < 0 {
+				return errors.New("unbalanced parentheses")
+			}
+		case char == ';':
+			return errors.New("statement separators are not allowed")
+		case char == '#', char == '-' && previous == '-', char == '*' && previous == '/':
+			return errors.New("comments are not allowed")
+		}
+
+		previous = char
+	}
+
+	if quote != 0 {
+		return errors.New("unterminated quoted string")
+	}
+
+	if depth != 0 {
+		return errors.New("unbalanced parentheses")
+	}
+
+	return nil
+}
+
+// Checks that the hint is empty or a single /* ... */ comment, so that it
+// cannot alter the verification queries.
+func validateReadHint(hint string) error {
+	if hint == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(hint, "/*") || !strings.HasSuffix(hint, "*/") || len(hint) < len("/**/") {
+		return errors.New("hint must be a /* ... */ comment")
+	}
+
+	if strings.Contains(hint[len("/*"):len(hint)-len("*/")], "*/") {
+		return errors.New("hint must be a single comment")
+	}
+
+	return nil
+}
+
+func parseIsolationLevel(level string) (sqlorig.IsolationLevel, error) {
+	switch strings.ToUpper(level) {
+	case "":
+		return sqlorig.LevelDefault, nil
+	case "READ UNCOMMITTED":
+		return sqlorig.LevelReadUncommitted, nil
+	case "READ COMMITTED":
+		return sqlorig.LevelReadCommitted, nil
+	case "REPEATABLE READ":
+		return sqlorig.LevelRepeatableRead, nil
+	case "SERIALIZABLE":
+		return sqlorig.LevelSerializable, nil
+	default:
+		return sqlorig.LevelDefault, fmt.Errorf("unsupported isolation level: %s", level)
+	}
+}
+
 // SchemaName => TableName => ColumnName => CompressionAlgorithm
 // Example: blog1 => articles => body => snappy
 //          (SELECT body FROM blog1.articles => returns compressed blob)
@@ -2,6 +2,7 @@ package ghostferry
 
 import (
 	sqlorig "database/sql"
+	"errors"
 	"fmt"
 	sql "github.com/Shopify/ghostferry/sqlwrapper"
 	"strings"
@@ -66,9 +67,29 @@ type Cursor struct {
 	MaxPaginationKey uint64
 	RowLock          bool
 
+	// If set, the rows are iterated from MaxPaginationKey down to the start
+	// paginationKey, instead of upward. BuildSelect is not supported when
+	// iterating in descending order.
+	Descending bool
+
+	// If set, only the rows matching this SQL predicate are iterated.
+	Where string
+
+	// If set, inserted after the SELECT keyword of the queries, such as an
+	// optimizer hint or a comment routing the queries to a replica.
+	Hint string
+
+	// If set, a slot of the limiter is held while the statement of a batch
+	// is open, see IterativeVerifier.MaxPreparedStatementsPerDB.
+	PreparedStatementLimiter *QueryLimiter
+
 	paginationKeyColumn         *schema.TableColumn
 	lastSuccessfulPaginationKey uint64
 	logger                      *logrus.Entry
+
+	// The largest paginationKey of the next batch when iterating in
+	// descending order.
+	upperPaginationKey uint64
 }
 
 func (c *Cursor) Each(f func(*RowBatch) error) error {
@@ -82,51 +103,66 @@ func (c *Cursor) Each(f func(*RowBatch) error) error {
 		c.ColumnsToSelect = []string{"*"}
 	}
 
-	for c.lastSuccessfulPaginationKey < c.MaxPaginationKey {
-		var tx SqlPreparerAndRollbacker
-		var batch *RowBatch
-		var paginationKeypos uint64
+	if c.Descending {
+		return c.eachDescending(f)
+	}
 
-		err := WithRetries(c.ReadRetries, 0, c.logger, "fetch rows", func() (err error) {
-			if c.Throttler != nil {
-				WaitForThrottle(c.Throttler)
-			}
+	for c.lastSuccessfulPaginationKey < c.MaxPaginationKey {
+		tx, batch, paginationKeypos, err := c.fetchWithRetries()
+		if err != nil {
+			return err
+		}
 
-			// Only need to use a transaction if RowLock == true. Otherwise
-			// we'd be wasting two extra round trips per batch, doing
-			// essentially a no-op.
-			if c.RowLock {
-				tx, err = c.DB.Begin()
-				if err != nil {
-					return err
-				}
-			} else {
-				tx = &SqlDBWithFakeRollback{c.DB}
-			}
+		if batch.Size() == 0 {
+			tx.Rollback()
+			c.logger.Debug("did not reach max primary key, but the table is complete as there are no more rows")
+			break
+		}
 
-			batch, paginationKeypos, err = c.Fetch(tx)
-			if err == nil {
-				return nil
-			}
+		if paginationKeypos <= c.lastSuccessfulPaginationKey {
+			tx.Rollback()
+			err = fmt.Errorf("new paginationKeypos %d <= lastSuccessfulPaginationKey %d", paginationKeypos, c.lastSuccessfulPaginationKey)
+			c.logger.WithError(err).Errorf("last successful paginationKey position did not advance")
+			return err
+		}
 
+		err = f(batch)
+		if err != nil {
 			tx.Rollback()
+			c.logger.WithError(err).Error("failed to call each callback")
 			return err
-		})
+		}
+
+		tx.Rollback()
+
+		c.lastSuccessfulPaginationKey = paginationKeypos
+	}
+
+	return nil
+}
+
+func (c *Cursor) eachDescending(f func(*RowBatch) error) error {
+	if c.BuildSelect != nil {
+		return errors.New("BuildSelect is not supported when iterating in descending order")
+	}
 
+	c.upperPaginationKey = c.MaxPaginationKey
+
+	for c.lastSuccessfulPaginationKey < c.upperPaginationKey {
+		tx, batch, paginationKeypos, err := c.fetchWithRetries()
 		if err != nil {
 			return err
 		}
 
 		if batch.Size() == 0 {
 			tx.Rollback()
-			c.logger.Debug("did not reach max primary key, but the table is complete as there are no more rows")
 			break
 		}
 
-		if paginationKeypos <= c.lastSuccessfulPaginationKey {
+		if paginationKeypos > c.upperPaginationKey {
 			tx.Rollback()
-			err = fmt.Errorf("new paginationKeypos %d <= lastSuccessfulPaginationKey %d", paginationKeypos, c.lastSuccessfulPaginationKey)
-			c.logger.WithError(err).Errorf("last successful paginationKey position did not advance")
+			err = fmt.Errorf("new paginationKeypos %d > upperPaginationKey %d", paginationKeypos, c.upperPaginationKey)
+			c.logger.WithError(err).Errorf("paginationKey position did not descend")
 			return err
 		}
 
@@ -139,16 +175,50 @@ func (c *Cursor) Each(f func(*RowBatch) error) error {
 
 		tx.Rollback()
 
-		c.lastSuccessfulPaginationKey = paginationKeypos
+		// The rows are fetched strictly above lastSuccessfulPaginationKey,
+		// so paginationKeypos cannot be 0.
+		c.upperPaginationKey = paginationKeypos - 1
 	}
 
 	return nil
 }
 
+func (c *Cursor) fetchWithRetries() (tx SqlPreparerAndRollbacker, batch *RowBatch, paginationKeypos uint64, err error) {
+	err = WithRetries(c.ReadRetries, 0, c.logger, "fetch rows", func() (err error) {
+		if c.Throttler != nil {
+			WaitForThrottle(c.Throttler)
+		}
+
+		// Only need to use a transaction if RowLock == true. Otherwise
+		// we'd be wasting two extra round trips per batch, doing
+		// essentially a no-op.
+		if c.RowLock {
+			tx, err = c.DB.Begin()
+			if err != nil {
+				return err
+			}
+		} else {
+			tx = &SqlDBWithFakeRollback{c.DB}
+		}
+
+		batch, paginationKeypos, err = c.Fetch(tx)
+		if err == nil {
+			return nil
+		}
+
+		tx.Rollback()
+		return err
+	})
+
+	return
+}
+
 func (c *Cursor) Fetch(db SqlPreparer) (batch *RowBatch, paginationKeypos uint64, err error) {
 	var selectBuilder squirrel.SelectBuilder
 
-	if c.BuildSelect != nil {
+	if c.Descending {
+		selectBuilder = DefaultBuildDescendingSelect(c.ColumnsToSelect, c.Table, c.lastSuccessfulPaginationKey, c.upperPaginationKey, c.BatchSize)
+	} else if c.BuildSelect != nil {
 		selectBuilder, err = c.BuildSelect(c.ColumnsToSelect, c.Table, c.lastSuccessfulPaginationKey, c.BatchSize)
 		if err != nil {
 			c.logger.WithError(err).Error("failed to apply filter for select")
@@ -158,6 +228,10 @@ func (c *Cursor) Fetch(db SqlPreparer) (batch *RowBatch, paginationKeypos uint64
 		selectBuilder = DefaultBuildSelect(c.ColumnsToSelect, c.Table, c.lastSuccessfulPaginationKey, c.BatchSize)
 	}
 
+	if c.Where != "" {
+		selectBuilder = selectBuilder.Where(fmt.Sprintf("(%s)", c.Where))
+	}
+
 	if c.RowLock {
 		selectBuilder = selectBuilder.Suffix("FOR UPDATE")
 	}
@@ -167,6 +241,7 @@ func (c *Cursor) Fetch(db SqlPreparer) (batch *RowBatch, paginationKeypos uint64
 		c.logger.WithError(err).Error("failed to build chunking sql")
 		return
 	}
+	query = insertQueryHint(query, c.Hint)
 
 	// With the inline verifier, the columns to be selected may be very large as
 	// the query generated will be very large. The code here simply hides the
@@ -183,6 +258,11 @@ func (c *Cursor) Fetch(db SqlPreparer) (batch *RowBatch, paginationKeypos uint64
 	// This query must be a prepared query. If it is not, querying will use
 	// MySQL's plain text interface, which will scan all values into []uint8
 	// if we give it []interface{}.
+	if c.PreparedStatementLimiter != nil {
+		c.PreparedStatementLimiter.Acquire()
+		defer c.PreparedStatementLimiter.Release()
+	}
+
 	stmt, err := db.Prepare(query)
 	if err != nil {
 		logger.WithError(err).Error("failed to prepare query")
@@ -280,6 +360,9 @@ func ScanByteRow(rows *sqlorig.Rows, columnCount int) ([][]byte, error) {
 	return values, err
 }
 
+// Selects the batch of rows following lastPaginationKey. As the batch is
+// bounded by its size rather than by a range of paginationKeys, gaps between
+// the paginationKeys do not result in empty batches.
 func DefaultBuildSelect(columns []string, table *TableSchema, lastPaginationKey, batchSize uint64) squirrel.SelectBuilder {
 	quotedPaginationKey := quoteField(table.GetPaginationColumn().Name)
 
@@ -289,3 +372,37 @@ func DefaultBuildSelect(columns []string, table *TableSchema, lastPaginationKey,
 		Limit(batchSize).
 		OrderBy(quotedPaginationKey)
 }
+
+// Selects the batch of rows with the largest paginationKeys that are greater
+// than lowerPaginationKey and at most upperPaginationKey, in descending order.
+func DefaultBuildDescendingSelect(columns []string, table *TableSchema, lowerPaginationKey, upperPaginationKey, batchSize uint64) squirrel.SelectBuilder {
+	quotedPaginationKey := quoteField(table.GetPaginationColumn().Name)
+
+	return squirrel.Select(columns...).
+		From(QuotedTableName(table)).
+		Where(squirrel.Gt{quotedPaginationKey: lowerPaginationKey}).
+		Where(squirrel.LtOrEq{quotedPaginationKey: upperPaginationKey}).
+		Limit(batchSize).
+		OrderBy(quotedPaginationKey + " DESC")
+}
+
+// Inserts the hint, a comment such as /*+ MAX_EXECUTION_TIME(1000) */, after
+// the leading SELECT keyword of the query, where both optimizer hints and
+// routing comments of proxies are recognized. The hint of an explained
+// query is inserted in the SELECT being explained. Queries not starting with
+// SELECT are prefixed with the hint.
+func insertQueryHint(query, hint string) string {
+	if hint == "" {
+		return query
+	}
+
+	if len(query) >= len("EXPLAIN ") && strings.EqualFold(query[:len("EXPLAIN ")], "EXPLAIN ") {
+		return query[:len("EXPLAIN ")] + insertQueryHint(query[len("EXPLAIN "):], hint)
+	}
+
+	if len(query) >= len("SELECT") && strings.EqualFold(query[:len("SELECT")], "SELECT") {
+		return query[:len("SELECT")] + " " + hint + query[len("SELECT"):]
+	}
+
+	return hint + " " + query
+}
@@ -30,11 +30,21 @@ func (r RowData) GetUint64(colIdx int) (res uint64, err error) {
 			return 0, err
 		}
 	} else {
-		signedInt := reflect.ValueOf(r[colIdx]).Int()
-		if signedInt < 0 {
-			return 0, fmt.Errorf("expected position %d in row to contain an unsigned number", colIdx)
+		value := reflect.ValueOf(r[colIdx])
+		switch value.Kind() {
+		// Unsigned values come from binlog events of unsigned columns, and
+		// may not fit in an int64.
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			res = value.Uint()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			signedInt := value.Int()
+			if signedInt < 0 {
+				return 0, fmt.Errorf("expected position %d in row to contain an unsigned number", colIdx)
+			}
+			res = uint64(signedInt)
+		default:
+			return 0, fmt.Errorf("expected position %d in row to contain an unsigned number, not %T", colIdx, r[colIdx])
 		}
-		res = uint64(signedInt)
 	}
 	return
 }
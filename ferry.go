@@ -235,60 +235,23 @@ func (f *Ferry) NewInlineVerifierWithoutStateTracker() *InlineVerifier {
 func (f *Ferry) NewIterativeVerifier() (*IterativeVerifier, error) {
 	f.ensureInitialized()
 
-	var err error
 	config := f.Config.IterativeVerifierConfig
-
-	var maxExpectedDowntime time.Duration
-	if config.MaxExpectedDowntime != "" {
-		maxExpectedDowntime, err = time.ParseDuration(config.MaxExpectedDowntime)
-		if err != nil {
-			return nil, fmt.Errorf("invalid MaxExpectedDowntime: %v. this error should have been caught via .Validate()", err)
-		}
-	}
-
-	var compressionVerifier *CompressionVerifier
-	if config.TableColumnCompression != nil {
-		compressionVerifier, err = NewCompressionVerifier(config.TableColumnCompression)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	ignoredColumns := make(map[string]map[string]struct{})
-	for table, columns := range config.IgnoredColumns {
-		ignoredColumns[table] = make(map[string]struct{})
-		for _, column := range columns {
-			ignoredColumns[table][column] = struct{}{}
-		}
-	}
-
-	v := &IterativeVerifier{
-		CursorConfig: &CursorConfig{
-			DB:          f.SourceDB,
-			BatchSize:   f.Config.DataIterationBatchSize,
-			ReadRetries: f.Config.DBReadRetries,
-		},
-
-		BinlogStreamer:      f.BinlogStreamer,
-		SourceDB:            f.SourceDB,
-		TargetDB:            f.TargetDB,
-		CompressionVerifier: compressionVerifier,
-
-		Tables:              f.Tables.AsSlice(),
-		TableSchemaCache:    f.Tables,
-		IgnoredTables:       config.IgnoredTables,
-		IgnoredColumns:      ignoredColumns,
-		DatabaseRewrites:    f.Config.DatabaseRewrites,
-		TableRewrites:       f.Config.TableRewrites,
-		Concurrency:         config.Concurrency,
-		MaxExpectedDowntime: maxExpectedDowntime,
+	config.Dependencies = IterativeVerifierDependencies{
+		SourceDB:         f.SourceDB,
+		TargetDB:         f.TargetDB,
+		BinlogStreamer:   f.BinlogStreamer,
+		TableSchemaCache: f.Tables,
+		BatchSize:        f.Config.DataIterationBatchSize,
+		ReadRetries:      f.Config.DBReadRetries,
+		DatabaseRewrites: f.Config.DatabaseRewrites,
+		TableRewrites:    f.Config.TableRewrites,
 	}
 
 	if f.CopyFilter != nil {
-		v.CursorConfig.BuildSelect = f.CopyFilter.BuildSelect
+		config.Dependencies.BuildSelect = f.CopyFilter.BuildSelect
 	}
 
-	return v, v.Initialize()
+	return NewIterativeVerifier(config)
 }
 
 // Initialize all the components of Ghostferry and connect to the Database
@@ -788,6 +751,9 @@ func (f *Ferry) SerializeStateToJSON() (string, error) {
 	}
 
 	serializedState := f.StateTracker.Serialize(f.Tables, binlogVerifyStore)
+	if iterativeVerifier, ok := f.Verifier.(*IterativeVerifier); ok {
+		serializedState.VerificationMismatches = iterativeVerifier.MismatchReport()
+	}
 
 	stateBytes, err := json.MarshalIndent(serializedState, "", " ")
 	return string(stateBytes), err
@@ -807,6 +773,10 @@ func (f *Ferry) Progress() *Progress {
 	s.BinlogStreamerLag = time.Now().Sub(f.BinlogStreamer.lastProcessedEventTime).Seconds()
 	s.FinalBinlogPos = f.BinlogStreamer.stopAtBinlogPosition
 
+	if iterativeVerifier, ok := f.Verifier.(*IterativeVerifier); ok {
+		s.VerificationMismatches = iterativeVerifier.MismatchReport()
+	}
+
 	// Table Progress
 	serializedState := f.StateTracker.Serialize(nil, nil)
 	s.Tables = make(map[string]TableProgress)
@@ -0,0 +1,226 @@
+package ghostferry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A query run to compare the fingerprints of a batch, as executed after the
+// read hints and the QueryRewriter are applied. The args are formatted as
+// strings, so that the paginationKeys beyond 2^53 are not rounded as JSON
+// numbers.
+type FingerprintQuery struct {
+	Side  string
+	Query string
+	Args  []string
+}
+
+// The inputs and the outputs of the comparison of the fingerprints of a batch
+// of rows, see IterativeVerifier.FingerprintRecorder. The hashes are those
+// compared, after the target-only rows are removed. For the tables of the
+// CompressionVerifier, the mismatches are those of the fingerprints, before
+// the CompressionVerifier compares them again with its own queries, which
+// are not recorded. The paginationKeys are encoded as JSON strings, see
+// FingerprintQuery.
+type FingerprintRecord struct {
+	Time           time.Time
+	Schema         string
+	Table          string
+	PaginationKeys []uint64
+	Queries        []FingerprintQuery
+	SourceHashes   map[uint64]string
+	TargetHashes   map[uint64]string
+	Mismatches     []uint64
+}
+
+type fingerprintRecordFields FingerprintRecord
+
+func (r FingerprintRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		fingerprintRecordFields
+		PaginationKeys []string
+		Mismatches     []string
+	}{
+		fingerprintRecordFields: fingerprintRecordFields(r),
+		PaginationKeys:          formatRecordedKeys(r.PaginationKeys),
+		Mismatches:              formatRecordedKeys(r.Mismatches),
+	})
+}
+
+func (r *FingerprintRecord) UnmarshalJSON(data []byte) error {
+	encoded := struct {
+		*fingerprintRecordFields
+		PaginationKeys []string
+		Mismatches     []string
+	}{fingerprintRecordFields: (*fingerprintRecordFields)(r)}
+
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	var err error
+	if r.PaginationKeys, err = parseRecordedKeys(encoded.PaginationKeys); err != nil {
+		return err
+	}
+
+	r.Mismatches, err = parseRecordedKeys(encoded.Mismatches)
+	return err
+}
+
+func formatRecordedKeys(keys []uint64) []string {
+	if keys == nil {
+		return nil
+	}
+
+	formatted := make([]string, len(keys))
+	for idx, key := range keys {
+		formatted[idx] = strconv.FormatUint(key, 10)
+	}
+
+	return formatted
+}
+
+func parseRecordedKeys(formatted []string) ([]uint64, error) {
+	if formatted == nil {
+		return nil, nil
+	}
+
+	keys := make([]uint64, len(formatted))
+	for idx, key := range formatted {
+		var err error
+		if keys[idx], err = strconv.ParseUint(key, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+type recordedQueriesContextKey struct{}
+
+// The queries run through readQuery with a context returned by
+// withQueryRecording.
+type recordedQueries struct {
+	mutex   sync.Mutex
+	queries []FingerprintQuery
+}
+
+func withQueryRecording(ctx context.Context) (context.Context, *recordedQueries) {
+	recorded := &recordedQueries{}
+	return context.WithValue(ctx, recordedQueriesContextKey{}, recorded), recorded
+}
+
+// Records the query if the context was returned by withQueryRecording.
+func recordQuery(ctx context.Context, side, query string, args []interface{}) {
+	recorded, ok := ctx.Value(recordedQueriesContextKey{}).(*recordedQueries)
+	if !ok {
+		return
+	}
+
+	formattedArgs := make([]string, len(args))
+	for idx, arg := range args {
+		if bytes, isBytes := arg.([]byte); isBytes {
+			formattedArgs[idx] = string(bytes)
+		} else {
+			formattedArgs[idx] = fmt.Sprint(arg)
+		}
+	}
+
+	recorded.mutex.Lock()
+	defer recorded.mutex.Unlock()
+
+	recorded.queries = append(recorded.queries, FingerprintQuery{Side: side, Query: query, Args: formattedArgs})
+}
+
+// Returns the queries recorded so far, those of the source first. The
+// queries of each side are in the order they were run.
+func (r *recordedQueries) sortedBySide() []FingerprintQuery {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	queries := make([]FingerprintQuery, len(r.queries))
+	copy(queries, r.queries)
+	sort.SliceStable(queries, func(i, j int) bool { return queries[i].Side < queries[j].Side })
+	return queries
+}
+
+// Returns a FingerprintRecorder that appends each record as a line of JSON
+// to the file at path. The file is opened for every record, which is slow
+// but fine for reproducing an issue.
+func NewFingerprintRecordFileWriter(path string) func(FingerprintRecord) error {
+	mutex := &sync.Mutex{}
+	return func(record FingerprintRecord) error {
+		recordBytes, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		if _, err := file.Write(append(recordBytes, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+
+		return file.Close()
+	}
+}
+
+// Reads the records written by NewFingerprintRecordFileWriter and compares
+// their recorded hashes again without a database, passing each record and
+// its mismatched paginationKeys to f. The mismatches can be checked against
+// the recorded ones to reproduce a verification offline.
+func ReplayFingerprintRecords(r io.Reader, f func(record FingerprintRecord, mismatches []uint64) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record FingerprintRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return err
+		}
+
+		mismatches := compareHashes(recordedHashes(record.SourceHashes), recordedHashes(record.TargetHashes))
+		if err := f(record, mismatches); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func recordHashes(hashes map[uint64][]byte) map[uint64]string {
+	recorded := make(map[uint64]string, len(hashes))
+	for paginationKey, hash := range hashes {
+		recorded[paginationKey] = string(hash)
+	}
+
+	return recorded
+}
+
+func recordedHashes(recorded map[uint64]string) map[uint64][]byte {
+	hashes := make(map[uint64][]byte, len(recorded))
+	for paginationKey, hash := range recorded {
+		hashes[paginationKey] = []byte(hash)
+	}
+
+	return hashes
+}
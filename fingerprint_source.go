@@ -0,0 +1,140 @@
+package ghostferry
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Provides the fingerprints of the target rows to the IterativeVerifier,
+// which queries them from the TargetDB unless another source is set as its
+// TargetFingerprintSource. The fingerprints must be computed the same way as
+// GetMd5HashesSql does.
+type FingerprintSource interface {
+	// Returns the fingerprints of the rows of the table with the given
+	// paginationKeys, keyed by paginationKey. Rows that do not exist are
+	// omitted.
+	GetHashes(ctx context.Context, table *TableSchema, paginationKeys []uint64) (map[uint64][]byte, error)
+}
+
+// The FingerprintSource querying the TargetDB of the verifier, from each of
+// the target tables the rows are resolved to.
+type targetDBFingerprintSource struct {
+	verifier *IterativeVerifier
+}
+
+func (s *targetDBFingerprintSource) GetHashes(ctx context.Context, table *TableSchema, paginationKeys []uint64) (map[uint64][]byte, error) {
+	v := s.verifier
+	hashes := make(map[uint64][]byte)
+	for _, partition := range v.targetPartitions(table, paginationKeys) {
+		if err := v.warnIfResolvedColumnOrderDiffers(table, partition.Db, partition.Table); err != nil {
+			return nil, err
+		}
+
+		var partitionHashes map[uint64][]byte
+		logger := v.batchLogger(ctx, table, "target", partition.Db, partition.Table, partition.PaginationKeys)
+		err := WithRetries(5, 0, logger, "get fingerprints from target db", func() (err error) {
+			partitionHashes, err = v.getHashesContext(ctx, v.TargetDB, "target", partition.Db, partition.Table, v.verificationKeyColumn(table), v.columnsToVerify(table), v.targetFingerprintOptions(table), partition.PaginationKeys)
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for paginationKey, hash := range partitionHashes {
+			hashes[paginationKey] = hash
+		}
+	}
+
+	return hashes, nil
+}
+
+// A FingerprintSource reading the fingerprints of each table from a CSV file
+// of paginationKey,fingerprint lines, such as exported from a dump of the
+// target with the query of GetMd5HashesSql. Each file is read entirely into
+// memory on first use.
+type FingerprintFileSource struct {
+	// Map of table name => path of the file of the table.
+	Files map[string]string
+
+	fingerprints map[string]map[uint64][]byte
+	mutex        sync.Mutex
+}
+
+func (s *FingerprintFileSource) GetHashes(_ context.Context, table *TableSchema, paginationKeys []uint64) (map[uint64][]byte, error) {
+	fingerprints, err := s.tableFingerprints(table)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[uint64][]byte, len(paginationKeys))
+	for _, paginationKey := range paginationKeys {
+		if fingerprint, exists := fingerprints[paginationKey]; exists {
+			hashes[paginationKey] = fingerprint
+		}
+	}
+
+	return hashes, nil
+}
+
+func (s *FingerprintFileSource) tableFingerprints(table *TableSchema) (map[uint64][]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if fingerprints, loaded := s.fingerprints[table.Name]; loaded {
+		return fingerprints, nil
+	}
+
+	path, exists := s.Files[table.Name]
+	if !exists {
+		return nil, fmt.Errorf("no fingerprint file for table %s", table.String())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fingerprints, err := ReadFingerprints(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fingerprint file %s: %v", path, err)
+	}
+
+	if s.fingerprints == nil {
+		s.fingerprints = make(map[string]map[uint64][]byte)
+	}
+	s.fingerprints[table.Name] = fingerprints
+
+	return fingerprints, nil
+}
+
+// Reads CSV lines of paginationKey,fingerprint into a map of paginationKey =>
+// fingerprint.
+func ReadFingerprints(r io.Reader) (map[uint64][]byte, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+
+	fingerprints := make(map[uint64][]byte)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return fingerprints, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		paginationKey, err := strconv.ParseUint(record[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		fingerprints[paginationKey] = []byte(record[1])
+	}
+}
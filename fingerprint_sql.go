@@ -0,0 +1,470 @@
+package ghostferry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/siddontang/go-mysql/schema"
+)
+
+// Options altering the fingerprint queries of a table.
+type FingerprintOptions struct {
+	// Map of column name => value that NULL in the column is considered
+	// equivalent to.
+	NullEquivalentValues map[string]string
+
+	// An index hint, such as FORCE INDEX (PRIMARY), added after the table name
+	// in the fingerprint queries.
+	IndexHint string
+
+	// SQL expressions that are fingerprinted after the columns.
+	AdditionalExpressions []string
+
+	// Set of column names whose values are lowercased before fingerprinting.
+	LowercasedColumns map[string]struct{}
+
+	// Set of column names whose integer or text values are normalized before
+	// fingerprinting, so that the values of a column widened by the migration
+	// hash the same on both sides.
+	WidenedColumns map[string]struct{}
+
+	// Set of column names whose values are compressed with COMPRESS(), which
+	// are decompressed with UNCOMPRESS() before fingerprinting.
+	UncompressedColumns map[string]struct{}
+
+	// A SQL predicate that the fingerprinted rows must match.
+	Where string
+
+	// Map of column name => SQL expression fingerprinted in place of the
+	// column, such as a transformation applied to the column by the
+	// migration.
+	ColumnTransformations map[string]string
+
+	// If positive, the hashes of the columns are concatenated and hashed in
+	// groups of this size, and the row fingerprint is the hash of the
+	// concatenated group hashes. This bounds the size of the CONCAT on very
+	// wide tables.
+	ColumnGroupSize int
+
+	// The function hashing the columns and the rows. Defaults to MD5.
+	HashFunction FingerprintHashFunction
+
+	// The format of the fingerprints. Defaults to FingerprintFormatV1.
+	Format FingerprintFormat
+
+	// The alias of the row fingerprint in the fingerprint queries, which must
+	// be an identifier that needs no quoting. Defaults to row_fingerprint.
+	// The queries of a table with a column of the same name are rejected.
+	RowFingerprintAlias string
+}
+
+// The SQL function hashing the values into the fingerprints of the rows.
+type FingerprintHashFunction string
+
+const (
+	FingerprintHashMD5 FingerprintHashFunction = "MD5"
+
+	// CRC32 is much cheaper to compute than MD5 on the servers, but its
+	// hashes only have 32 bits. Two different rows have a one in 2^32
+	// chance of having the same fingerprint, which goes unnoticed. This is
+	// meant for verifications where throughput matters more than the
+	// certainty that every mismatch is found.
+	FingerprintHashCRC32 FingerprintHashFunction = "CRC32"
+)
+
+// The format of the values hashed into the fingerprints of the rows. The
+// fingerprints of a format differ from those of the other formats, so that a
+// TargetFingerprintSource must be exported in the format it is verified with.
+type FingerprintFormat int
+
+const (
+	// NULL values are hashed as the string 'NULL', so that NULL and the
+	// string 'NULL' have the same fingerprint.
+	FingerprintFormatV1 FingerprintFormat = 1
+
+	// NULL values are hashed differently from every other value, including
+	// the string 'NULL'.
+	FingerprintFormatV2 FingerprintFormat = 2
+)
+
+// Returns a non-NULL SQL expression for the value of the expression. In
+// FingerprintFormatV2, the values are prefixed with '0' while NULL becomes
+// '1', so that NULL differs from every other value.
+func (f FingerprintFormat) nonNullValue(expression string) string {
+	if f == FingerprintFormatV2 {
+		return fmt.Sprintf("COALESCE(CONCAT('0', %s), '1')", expression)
+	}
+
+	return fmt.Sprintf("COALESCE(%s, 'NULL')", expression)
+}
+
+// Returns the SQL expression hashing the expression. The CRC32 hashes are
+// zero-padded hexadecimal strings, so that like the MD5 hashes, they have a
+// fixed length and can be concatenated without a separator.
+func (f FingerprintHashFunction) hash(expression string) string {
+	if f == FingerprintHashCRC32 {
+		return fmt.Sprintf("LPAD(HEX(CRC32(%s)), 8, '0')", expression)
+	}
+
+	return fmt.Sprintf("MD5(%s)", expression)
+}
+
+func GetMd5HashesSql(schema, table, paginationKeyColumn string, columns []schema.TableColumn, paginationKeys []uint64) (string, []interface{}, error) {
+	return GetMd5HashesSqlWithOptions(schema, table, paginationKeyColumn, columns, FingerprintOptions{}, paginationKeys)
+}
+
+// GetMd5HashesSql with the options of the fingerprints.
+func GetMd5HashesSqlWithOptions(schema, table, paginationKeyColumn string, columns []schema.TableColumn, options FingerprintOptions, paginationKeys []uint64) (string, []interface{}, error) {
+	alias, err := rowFingerprintAlias(columns, options)
+	if err != nil {
+		return "", nil, err
+	}
+
+	quotedPaginationKey := quoteField(paginationKeyColumn)
+	return rowMd5Selector(columns, options, paginationKeyColumn, alias).
+		From(fingerprintedTable(schema, table, options)).
+		Where(sq.Eq{quotedPaginationKey: paginationKeys}).
+		Where(fingerprintedRowsPredicate(options)).
+		OrderBy(quotedPaginationKey).
+		ToSql()
+}
+
+// Selects the paginationKeys of the rows that exist among the given
+// paginationKeys, without fingerprinting them.
+func GetExistingPaginationKeysSql(schema, table, paginationKeyColumn string, options FingerprintOptions, paginationKeys []uint64) (string, []interface{}, error) {
+	quotedPaginationKey := quoteField(paginationKeyColumn)
+	return sq.Select(quotedPaginationKey).
+		From(fingerprintedTable(schema, table, options)).
+		Where(sq.Eq{quotedPaginationKey: paginationKeys}).
+		Where(fingerprintedRowsPredicate(options)).
+		ToSql()
+}
+
+// Selects the paginationKey and the fingerprint of each column separately,
+// unlike GetMd5HashesSql, which fingerprints the row as a whole.
+//
+// Unlike in the row fingerprints, see FingerprintFormat, NULL values are not
+// coalesced, so that the fingerprint of a NULL value is NULL. The columns
+// compare like MySQL's NULL-safe <=> operator: NULL on both sides is equal,
+// while NULL and any value, including the string 'NULL', differ.
+func GetMd5ColumnHashesSql(schema, table, paginationKeyColumn string, columns []schema.TableColumn, options FingerprintOptions, paginationKeys []uint64) (string, []interface{}, error) {
+	quotedPaginationKey := quoteField(paginationKeyColumn)
+
+	selects := []string{quotedPaginationKey}
+	for _, column := range columns {
+		selects = append(selects, options.HashFunction.hash(normalizeAndQuoteColumn(column, options)))
+	}
+
+	for _, expression := range options.AdditionalExpressions {
+		selects = append(selects, options.HashFunction.hash(expression))
+	}
+
+	return sq.Select(strings.Join(selects, ", ")).
+		From(fingerprintedTable(schema, table, options)).
+		Where(sq.Eq{quotedPaginationKey: paginationKeys}).
+		Where(fingerprintedRowsPredicate(options)).
+		OrderBy(quotedPaginationKey).
+		ToSql()
+}
+
+// Returns the number of rows and the BIT_XOR of the first 64 bits of the row
+// fingerprints for the given paginationKeys.
+func GetMd5BatchChecksumSql(schema, table, paginationKeyColumn string, columns []schema.TableColumn, options FingerprintOptions, paginationKeys []uint64) (string, []interface{}, error) {
+	quotedPaginationKey := quoteField(paginationKeyColumn)
+	return sq.Select(fmt.Sprintf(
+		"COUNT(*), COALESCE(BIT_XOR(CAST(CONV(SUBSTRING(%s, 1, 16), 16, 10) AS UNSIGNED)), 0)",
+		rowMd5Expression(columns, options),
+	)).
+		From(fingerprintedTable(schema, table, options)).
+		Where(sq.Eq{quotedPaginationKey: paginationKeys}).
+		Where(fingerprintedRowsPredicate(options)).
+		ToSql()
+}
+
+// Returns the number of rows and the BIT_XOR of the first 64 bits of the row
+// fingerprints for the rows whose paginationKey is greater than
+// lowPaginationKey and at most highPaginationKey.
+func GetMd5WindowChecksumSql(schema, table, paginationKeyColumn string, columns []schema.TableColumn, options FingerprintOptions, lowPaginationKey, highPaginationKey uint64) (string, []interface{}, error) {
+	quotedPaginationKey := quoteField(paginationKeyColumn)
+	return sq.Select(fmt.Sprintf(
+		"COUNT(*), COALESCE(BIT_XOR(CAST(CONV(SUBSTRING(%s, 1, 16), 16, 10) AS UNSIGNED)), 0)",
+		rowMd5Expression(columns, options),
+	)).
+		From(fingerprintedTable(schema, table, options)).
+		Where(sq.Gt{quotedPaginationKey: lowPaginationKey}).
+		Where(sq.LtOrEq{quotedPaginationKey: highPaginationKey}).
+		Where(fingerprintedRowsPredicate(options)).
+		ToSql()
+}
+
+// Selects the largest paginationKey of the rows, or 0 if there are none.
+func GetMaxPaginationKeySql(schema, table, paginationKeyColumn string, options FingerprintOptions) (string, []interface{}, error) {
+	// See GetDistributionSql as for why the predicate is only added if set.
+	query := sq.Select(fmt.Sprintf("COALESCE(MAX(%s), 0)", quoteField(paginationKeyColumn))).
+		From(QuotedTableNameFromString(schema, table))
+	if options.Where != "" {
+		query = query.Where(fingerprintedRowsPredicate(options))
+	}
+
+	return query.ToSql()
+}
+
+// Selects the number of rows whose paginationKey is greater than
+// paginationKey, and the largest of their paginationKeys, or 0 if there are
+// none.
+func GetRowsAbovePaginationKeySql(schema, table, paginationKeyColumn string, options FingerprintOptions, paginationKey uint64) (string, []interface{}, error) {
+	quotedPaginationKey := quoteField(paginationKeyColumn)
+	return sq.Select(fmt.Sprintf("COUNT(*), COALESCE(MAX(%s), 0)", quotedPaginationKey)).
+		From(QuotedTableNameFromString(schema, table)).
+		Where(sq.Gt{quotedPaginationKey: paginationKey}).
+		Where(fingerprintedRowsPredicate(options)).
+		ToSql()
+}
+
+// Selects the given aggregates over the rows of the table matching the Where
+// of the options.
+func GetAggregatesSql(schema, table string, aggregates []Aggregate, options FingerprintOptions) (string, []interface{}, error) {
+	expressions := make([]string, len(aggregates))
+	for idx, aggregate := range aggregates {
+		expressions[idx] = aggregate.expression()
+	}
+
+	// See GetDistributionSql as for why the predicate is only added if set.
+	query := sq.Select(expressions...).From(QuotedTableNameFromString(schema, table))
+	if options.Where != "" {
+		query = query.Where(fingerprintedRowsPredicate(options))
+	}
+
+	return query.ToSql()
+}
+
+// Selects the number of rows of the table by value of the column.
+func GetDistributionSql(schema, table, column string, options FingerprintOptions) (string, []interface{}, error) {
+	quotedColumn := quoteField(column)
+
+	// Unlike in the other queries, the predicate is the only condition, and
+	// squirrel renders an empty WHERE for a nil predicate.
+	query := sq.Select(quotedColumn, "COUNT(*)").From(QuotedTableNameFromString(schema, table))
+	if options.Where != "" {
+		query = query.Where(fingerprintedRowsPredicate(options))
+	}
+
+	return query.GroupBy(quotedColumn).ToSql()
+}
+
+// Returns the predicate of FingerprintOptions.Where, or nil if it is not set.
+func fingerprintedRowsPredicate(options FingerprintOptions) interface{} {
+	if options.Where == "" {
+		return nil
+	}
+
+	return fmt.Sprintf("(%s)", options.Where)
+}
+
+func fingerprintedTable(schema, table string, options FingerprintOptions) string {
+	quotedTable := QuotedTableNameFromString(schema, table)
+	if options.IndexHint == "" {
+		return quotedTable
+	}
+
+	return fmt.Sprintf("%s %s", quotedTable, options.IndexHint)
+}
+
+func rowMd5Selector(columns []schema.TableColumn, options FingerprintOptions, paginationKeyColumn, alias string) sq.SelectBuilder {
+	quotedPaginationKey := quoteField(paginationKeyColumn)
+
+	return sq.Select(fmt.Sprintf(
+		"%s, %s AS %s",
+		quotedPaginationKey,
+		rowMd5Expression(columns, options),
+		alias,
+	))
+}
+
+const defaultRowFingerprintAlias = "row_fingerprint"
+
+var rowFingerprintAliasRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Returns the alias of the row fingerprint, which must differ from the names
+// of the columns so that the fingerprint cannot be mistaken for a column by
+// the readers of the query, such as a QueryRewriter.
+func rowFingerprintAlias(columns []schema.TableColumn, options FingerprintOptions) (string, error) {
+	alias := options.RowFingerprintAlias
+	if alias == "" {
+		alias = defaultRowFingerprintAlias
+	}
+
+	for _, column := range columns {
+		if strings.EqualFold(column.Name, alias) {
+			return "", fmt.Errorf("row fingerprint alias %s collides with column %s, see RowFingerprintAlias", alias, column.Name)
+		}
+	}
+
+	return alias, nil
+}
+
+// Each column is hashed separately before the hashes are concatenated. As the
+// hashes have a fixed length, the column boundaries are unambiguous without a
+// separator: values shifted between adjacent columns, such as ("a", "bc") and
+// ("ab", "c"), do not result in the same fingerprint. Adding a separator would
+// change the fingerprints exported for a TargetFingerprintSource.
+func rowMd5Expression(columns []schema.TableColumn, options FingerprintOptions) string {
+	hashStrs := make([]string, 0, len(columns)+len(options.AdditionalExpressions))
+	for _, column := range columns {
+		quotedCol := normalizeAndQuoteColumn(column, options)
+		hashStrs = append(hashStrs, options.HashFunction.hash(options.Format.nonNullValue(quotedCol)))
+	}
+
+	for _, expression := range options.AdditionalExpressions {
+		hashStrs = append(hashStrs, options.HashFunction.hash(options.Format.nonNullValue(expression)))
+	}
+
+	if options.ColumnGroupSize <= 0 || len(hashStrs) <= options.ColumnGroupSize {
+		return options.HashFunction.hash(fmt.Sprintf("CONCAT(%s)", strings.Join(hashStrs, ",")))
+	}
+
+	groupHashStrs := make([]string, 0, len(hashStrs)/options.ColumnGroupSize+1)
+	for start := 0; start < len(hashStrs); start += options.ColumnGroupSize {
+		end := start + options.ColumnGroupSize
+		if end > len(hashStrs) {
+			end = len(hashStrs)
+		}
+
+		groupHashStrs = append(groupHashStrs, options.HashFunction.hash(fmt.Sprintf("CONCAT(%s)", strings.Join(hashStrs[start:end], ","))))
+	}
+
+	return options.HashFunction.hash(fmt.Sprintf("CONCAT(%s)", strings.Join(groupHashStrs, ",")))
+}
+
+// Columns in the UncompressedColumns of the options are decompressed, and
+// columns in the LowercasedColumns of the options are lowercased. If the
+// options contain a NULL-equivalent value for the column, NULL values of the
+// column are replaced with the equivalent value so that both fingerprint the
+// same.
+func normalizeAndQuoteColumn(column schema.TableColumn, options FingerprintOptions) (quoted string) {
+	quoted = quoteField(column.Name)
+	if transformation, exists := options.ColumnTransformations[column.Name]; exists {
+		quoted = fmt.Sprintf("(%s)", transformation)
+	}
+
+	// The decompressed value is a binary string, so the column is not
+	// normalized according to its own type.
+	_, compressed := options.UncompressedColumns[column.Name]
+	if compressed {
+		quoted = fmt.Sprintf("UNCOMPRESS(%s)", quoted)
+	}
+
+	if !compressed && column.Type == schema.TYPE_FLOAT {
+		quoted = fmt.Sprintf("(if (%s = '-0', 0, %s))", quoted, quoted)
+	}
+
+	// The integers of widened columns are hashed as their values rather than
+	// as formatted by their type, so that a column widened to a larger
+	// integer type, or with a different ZEROFILL display width, hashes the
+	// same.
+	_, widened := options.WidenedColumns[column.Name]
+	if widened && !compressed && isIntegerColumn(column) {
+		if column.IsUnsigned {
+			quoted = fmt.Sprintf("CAST(%s AS UNSIGNED)", quoted)
+		} else {
+			quoted = fmt.Sprintf("CAST(%s AS SIGNED)", quoted)
+		}
+	}
+
+	// The text of widened columns is hashed in a single character set, so
+	// that a column widened to a larger character set, such as from latin1 to
+	// utf8mb4, hashes the same. Widening its length alone does not change its
+	// values.
+	if widened && !compressed && isTextColumn(column) {
+		quoted = fmt.Sprintf("CONVERT(%s USING utf8mb4)", quoted)
+	}
+
+	// Binary columns are hashed over their exact bytes, including trailing
+	// spaces and 0x00 padding, so the result does not depend on how the
+	// connection or the server collation treats the value.
+	if !compressed && isBinaryStringColumn(column) {
+		quoted = fmt.Sprintf("CAST(%s AS BINARY)", quoted)
+	}
+
+	// MySQL strips the trailing spaces of CHAR values when reading them, but
+	// not those of VARCHAR values. The trailing spaces are trimmed on both
+	// sides so that a CHAR column migrated to VARCHAR fingerprints the same.
+	if !compressed && isCharColumn(column) {
+		quoted = fmt.Sprintf("RTRIM(%s)", quoted)
+	}
+
+	// Geometries are hashed as their SRID and their WKT, so that geometries
+	// with the same coordinates but different SRIDs, which behave differently
+	// in spatial queries, are found to differ.
+	if !compressed && isGeometryColumn(column) {
+		quoted = fmt.Sprintf("CONCAT(ST_SRID(%s), ':', ST_AsText(%s))", quoted, quoted)
+	}
+
+	if _, lowercased := options.LowercasedColumns[column.Name]; lowercased {
+		quoted = fmt.Sprintf("LOWER(%s)", quoted)
+	}
+
+	if nullEquivalentValue, exists := options.NullEquivalentValues[column.Name]; exists {
+		quoted = fmt.Sprintf("COALESCE(%s, %s)", quoted, appendEscapedString(nil, nullEquivalentValue))
+	}
+	return
+}
+
+func isIntegerColumn(column schema.TableColumn) bool {
+	return column.Type == schema.TYPE_NUMBER && !strings.HasPrefix(strings.ToLower(column.RawType), "year")
+}
+
+var textTypes = []string{"char", "varchar", "tinytext", "text", "mediumtext", "longtext"}
+
+func isTextColumn(column schema.TableColumn) bool {
+	if column.Type != schema.TYPE_STRING {
+		return false
+	}
+
+	rawType := strings.ToLower(column.RawType)
+	for _, textType := range textTypes {
+		if strings.HasPrefix(rawType, textType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isCharColumn(column schema.TableColumn) bool {
+	if column.Type != schema.TYPE_STRING {
+		return false
+	}
+
+	return strings.HasPrefix(strings.ToLower(column.RawType), "char")
+}
+
+var geometryTypes = []string{
+	"geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection", "geomcollection",
+}
+
+func isGeometryColumn(column schema.TableColumn) bool {
+	if column.Type != schema.TYPE_STRING {
+		return false
+	}
+
+	rawType := strings.ToLower(column.RawType)
+	for _, geometryType := range geometryTypes {
+		if strings.HasPrefix(rawType, geometryType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isBinaryStringColumn(column schema.TableColumn) bool {
+	if column.Type != schema.TYPE_STRING {
+		return false
+	}
+
+	rawType := strings.ToLower(column.RawType)
+	return strings.HasPrefix(rawType, "binary") || strings.HasPrefix(rawType, "varbinary")
+}
@@ -0,0 +1,238 @@
+package ghostferry
+
+import (
+	"context"
+	sqlorig "database/sql"
+	"fmt"
+	"strings"
+
+	sql "github.com/Shopify/ghostferry/sqlwrapper"
+)
+
+// An aggregate of a column of a table compared between the source and the
+// target, see IterativeVerifier.Aggregates.
+type Aggregate struct {
+	// One of SUM, MIN, MAX or COUNT.
+	Function string
+
+	// The aggregated column, or * for COUNT(*).
+	Column string
+}
+
+// Parses an aggregate such as SUM(amount), MAX(id) or COUNT(*).
+func ParseAggregate(aggregate string) (Aggregate, error) {
+	open := strings.Index(aggregate, "(")
+	if open < 0 || !strings.HasSuffix(aggregate, ")") {
+		return Aggregate{}, fmt.Errorf("invalid aggregate: %s", aggregate)
+	}
+
+	function := strings.ToUpper(strings.TrimSpace(aggregate[:open]))
+	column := strings.TrimSpace(aggregate[open+1 : len(aggregate)-1])
+
+	switch function {
+	case "SUM", "MIN", "MAX", "COUNT":
+	default:
+		return Aggregate{}, fmt.Errorf("unknown aggregate function in %s, must be one of SUM, MIN, MAX or COUNT", aggregate)
+	}
+
+	if column == "" || strings.ContainsAny(column, "`()") || (column == "*" && function != "COUNT") {
+		return Aggregate{}, fmt.Errorf("invalid column in aggregate: %s", aggregate)
+	}
+
+	return Aggregate{Function: function, Column: column}, nil
+}
+
+func (a Aggregate) String() string {
+	return fmt.Sprintf("%s(%s)", a.Function, a.Column)
+}
+
+func (a Aggregate) expression() string {
+	if a.Column == "*" {
+		return a.String()
+	}
+
+	return fmt.Sprintf("%s(%s)", a.Function, quoteField(a.Column))
+}
+
+// Compares the Aggregates of each table between the source and the target.
+func (v *IterativeVerifier) compareAggregates(tables []*TableSchema) (VerificationResult, error) {
+	var differences []string
+	var incorrectTables []string
+
+	for _, table := range tables {
+		aggregates := v.Aggregates[table.Name]
+		if v.tableIsIgnored(table) || len(aggregates) == 0 {
+			continue
+		}
+
+		sourceValues, err := v.queryAggregates(v.SourceDB, "source", table.Schema, table.Name, aggregates, FingerprintOptions{})
+		if err != nil {
+			return VerificationResult{}, err
+		}
+
+		// Only the rows of the target merged from this table are aggregated.
+		targetDb, targetTable := v.targetTableName(table)
+		targetOptions := FingerprintOptions{Where: v.MergedTablePredicates[table.Name]}
+		targetValues, err := v.queryAggregates(v.TargetDB, "target", targetDb, targetTable, aggregates, targetOptions)
+		if err != nil {
+			return VerificationResult{}, err
+		}
+
+		tableDiffers := false
+		for idx, aggregate := range aggregates {
+			if sourceValues[idx] == targetValues[idx] {
+				continue
+			}
+
+			differences = append(differences, fmt.Sprintf(
+				"%s of table %s is %s on the source but %s on the target",
+				aggregate.String(),
+				table.String(),
+				aggregateValueString(sourceValues[idx]),
+				aggregateValueString(targetValues[idx]),
+			))
+			tableDiffers = true
+		}
+
+		if tableDiffers {
+			incorrectTables = append(incorrectTables, table.String())
+		}
+	}
+
+	if len(differences) == 0 {
+		return NewCorrectVerificationResult(), nil
+	}
+
+	v.logger.WithField("differences", differences).Error("aggregates differ between the source and the target")
+
+	return VerificationResult{
+		DataCorrect:     false,
+		Message:         fmt.Sprintf("aggregates differ: %s", strings.Join(differences, "; ")),
+		IncorrectTables: incorrectTables,
+	}, nil
+}
+
+func (v *IterativeVerifier) queryAggregates(db *sql.DB, side, schemaName, tableName string, aggregates []Aggregate, options FingerprintOptions) ([]sqlorig.NullString, error) {
+	query, args, err := GetAggregatesSql(schemaName, tableName, aggregates, options)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]sqlorig.NullString, len(aggregates))
+	valuePtrs := make([]interface{}, len(aggregates))
+	for idx := range values {
+		valuePtrs[idx] = &values[idx]
+	}
+
+	err = v.readQueryRow(context.Background(), db, side, query, args, valuePtrs...)
+	return values, err
+}
+
+// Compares the distributions of the values of the DistributionColumns of each
+// table between the source and the target.
+func (v *IterativeVerifier) compareDistributions(tables []*TableSchema) (VerificationResult, error) {
+	var differences []string
+	var incorrectTables []string
+
+	for _, table := range tables {
+		columns := v.DistributionColumns[table.Name]
+		if v.tableIsIgnored(table) || len(columns) == 0 {
+			continue
+		}
+
+		targetDb, targetTable := v.targetTableName(table)
+		options := FingerprintOptions{Where: v.VerifyWhere[table.Name]}
+		targetOptions := FingerprintOptions{Where: andPredicates(options.Where, v.MergedTablePredicates[table.Name])}
+
+		tableDiffers := false
+		for _, column := range columns {
+			sourceCounts, err := v.queryDistribution(v.SourceDB, "source", table.Schema, table.Name, column, options)
+			if err != nil {
+				return VerificationResult{}, err
+			}
+
+			targetCounts, err := v.queryDistribution(v.TargetDB, "target", targetDb, targetTable, column, targetOptions)
+			if err != nil {
+				return VerificationResult{}, err
+			}
+
+			values := make(map[string]struct{})
+			for value := range sourceCounts {
+				values[value] = struct{}{}
+			}
+			for value := range targetCounts {
+				values[value] = struct{}{}
+			}
+
+			for _, value := range sortedSetKeys(values) {
+				if sourceCounts[value] == targetCounts[value] {
+					continue
+				}
+
+				differences = append(differences, fmt.Sprintf(
+					"%s = %s of table %s has %d rows on the source but %d on the target",
+					column,
+					value,
+					table.String(),
+					sourceCounts[value],
+					targetCounts[value],
+				))
+				tableDiffers = true
+			}
+		}
+
+		if tableDiffers {
+			incorrectTables = append(incorrectTables, table.String())
+		}
+	}
+
+	if len(differences) == 0 {
+		return NewCorrectVerificationResult(), nil
+	}
+
+	v.logger.WithField("differences", differences).Error("distributions differ between the source and the target")
+
+	return VerificationResult{
+		DataCorrect:     false,
+		Message:         fmt.Sprintf("distributions differ: %s", strings.Join(differences, "; ")),
+		IncorrectTables: incorrectTables,
+	}, nil
+}
+
+// Returns the number of rows of the table matching the Where of the options
+// by value of the column, with NULL values counted as "NULL".
+func (v *IterativeVerifier) queryDistribution(db *sql.DB, side, schemaName, tableName, column string, options FingerprintOptions) (map[string]uint64, error) {
+	query, args, err := GetDistributionSql(schemaName, tableName, column, options)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, release, err := v.readQuery(context.Background(), db, side, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+	defer rows.Close()
+
+	counts := make(map[string]uint64)
+	for rows.Next() {
+		var value sqlorig.NullString
+		var count uint64
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, err
+		}
+
+		counts[aggregateValueString(value)] = count
+	}
+
+	return counts, rows.Err()
+}
+
+func aggregateValueString(value sqlorig.NullString) string {
+	if !value.Valid {
+		return "NULL"
+	}
+
+	return value.String
+}
@@ -0,0 +1,234 @@
+package ghostferry
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sirupsen/logrus"
+)
+
+// Repairs the mismatched rows of the result, see EnableRepair, and returns
+// the result of verifying them again.
+func (v *IterativeVerifier) repairMismatches(result VerificationResult) (VerificationResult, error) {
+	if len(result.Mismatches) == 0 {
+		return result, nil
+	}
+
+	mismatchesByTable := make(map[TableIdentifier][]VerificationMismatch)
+	tableIds := make([]TableIdentifier, 0)
+	for _, mismatch := range result.Mismatches {
+		if _, exists := mismatchesByTable[mismatch.Table]; !exists {
+			tableIds = append(tableIds, mismatch.Table)
+		}
+		mismatchesByTable[mismatch.Table] = append(mismatchesByTable[mismatch.Table], mismatch)
+	}
+
+	var failures []verificationResultAndError
+	var repaired []VerificationMismatch
+	ctx := v.traceContext()
+
+	for _, tableId := range tableIds {
+		table, err := v.reverifyTableSchema(tableId)
+		if err != nil {
+			return VerificationResult{}, err
+		}
+
+		mismatches := mismatchesByTable[tableId]
+		paginationKeys := make([]uint64, len(mismatches))
+		for idx, mismatch := range mismatches {
+			paginationKeys[idx] = mismatch.PaginationKey
+		}
+
+		logger := v.logger.WithFields(logrus.Fields{
+			"table":          table.String(),
+			"paginationKeys": paginationKeys,
+		})
+
+		if !v.repairSupported(table) || v.RepairDryRun {
+			if v.RepairDryRun {
+				logger.Warn("dry run: would repair mismatched rows")
+			} else {
+				logger.Warn("cannot repair mismatched rows of table")
+			}
+
+			tableResult := newMismatchedPaginationKeysResult(table, paginationKeys)
+			tableResult.Mismatches = mismatches
+			failures = append(failures, verificationResultAndError{Result: tableResult})
+			continue
+		}
+
+		logger.Warn("repairing mismatched rows")
+		if err := v.repairRows(ctx, table, paginationKeys); err != nil {
+			return VerificationResult{}, err
+		}
+
+		tableResult, mismatchedPaginationKeys, err := v.reverifyPaginationKeys(ctx, table, paginationKeys)
+		if err != nil {
+			return VerificationResult{}, err
+		}
+
+		stillMismatched := make(map[uint64]struct{}, len(mismatchedPaginationKeys))
+		for _, paginationKey := range mismatchedPaginationKeys {
+			stillMismatched[paginationKey] = struct{}{}
+		}
+
+		for _, mismatch := range mismatches {
+			if _, exists := stillMismatched[mismatch.PaginationKey]; !exists {
+				repaired = append(repaired, mismatch)
+			}
+		}
+
+		if !tableResult.DataCorrect {
+			logger.WithField("stillMismatched", mismatchedPaginationKeys).Error("rows still mismatched after repair")
+			failures = append(failures, verificationResultAndError{Result: tableResult})
+		}
+	}
+
+	repairedResult, err := mergeFailedVerificationResults(failures)
+	if len(failures) == 0 {
+		repairedResult = NewCorrectVerificationResult()
+	}
+
+	repairedResult.RepairedMismatches = repaired
+	return repairedResult, err
+}
+
+// Rows can only be repaired when they are copied as is to a single target
+// table and identified by their paginationKey.
+func (v *IterativeVerifier) repairSupported(table *TableSchema) bool {
+	if !v.targetIsLive() || len(v.ComputedColumns[table.Name]) > 0 || len(v.ColumnTransformations[table.Name]) > 0 || len(v.TargetMysqlCompressedColumns[table.Name]) > 0 {
+		return false
+	}
+
+	if _, exists := v.TargetResolvers[table.Name]; exists {
+		return false
+	}
+
+	if v.isMergedTable(table) {
+		return false
+	}
+
+	return v.verificationKeyColumn(table) == table.GetPaginationColumn().Name
+}
+
+// Returns whether the table is merged with other tables into its target
+// table, either scoped by its MergedTablePredicates or because another
+// verified table has the same target table.
+func (v *IterativeVerifier) isMergedTable(table *TableSchema) bool {
+	if v.MergedTablePredicates[table.Name] != "" {
+		return true
+	}
+
+	targetDb, targetTable := v.targetTableName(table)
+	for _, other := range v.snapshotTables() {
+		if other.Schema == table.Schema && other.Name == table.Name {
+			continue
+		}
+
+		otherDb, otherTable := v.targetTableName(other)
+		if otherDb == targetDb && otherTable == targetTable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Copies the rows with the paginationKeys from the source to the target,
+// updating the existing rows of the target in place, and deletes the rows
+// that do not exist on the source from the target.
+func (v *IterativeVerifier) repairRows(ctx context.Context, table *TableSchema, paginationKeys []uint64) error {
+	paginationColumn := table.GetPaginationColumn().Name
+	paginationKeyIndex := -1
+	for idx, column := range table.Columns {
+		if column.Name == paginationColumn {
+			paginationKeyIndex = idx
+			break
+		}
+	}
+
+	if paginationKeyIndex < 0 {
+		return fmt.Errorf("paginationKey column %s is not found in table %s", paginationColumn, table.String())
+	}
+
+	quotedPaginationKey := quoteField(paginationColumn)
+	query, args, err := sq.Select(quotedColumnNames(table)...).
+		From(QuotedTableNameFromString(table.Schema, table.Name)).
+		Where(sq.Eq{quotedPaginationKey: paginationKeys}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, release, err := v.readQuery(ctx, v.SourceDB, "source", query, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer rows.Close()
+
+	var values []RowData
+	existsOnSource := make(map[uint64]struct{})
+	for rows.Next() {
+		rowData, err := ScanGenericRow(rows, len(table.Columns))
+		if err != nil {
+			return err
+		}
+
+		paginationKey, err := rowData.GetUint64(paginationKeyIndex)
+		if err != nil {
+			return err
+		}
+
+		values = append(values, rowData)
+		existsOnSource[paginationKey] = struct{}{}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var missingOnSource []uint64
+	for _, paginationKey := range paginationKeys {
+		if _, exists := existsOnSource[paginationKey]; !exists {
+			missingOnSource = append(missingOnSource, paginationKey)
+		}
+	}
+
+	targetDb, targetTable := v.targetTableName(table)
+	tx, err := v.TargetDB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if len(values) > 0 {
+		query, args, err := NewRowBatch(table, values, paginationKeyIndex).AsUpsertSQLQuery(targetDb, targetTable)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if len(missingOnSource) > 0 {
+		query, args, err := sq.Delete(QuotedTableNameFromString(targetDb, targetTable)).
+			Where(sq.Eq{quotedPaginationKey: missingOnSource}).
+			ToSql()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,167 @@
+package ghostferry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	sq "github.com/Masterminds/squirrel"
+	sql "github.com/Shopify/ghostferry/sqlwrapper"
+)
+
+// The signature of a table, see IterativeVerifier.TableSignatureFile.
+type TableSignature struct {
+	RowCount         uint64
+	MaxPaginationKey uint64
+
+	// The latest modification time of the rows, if the table has a
+	// modification timestamp column.
+	MaxModificationTime string
+}
+
+// The signatures of a table on the source and the target when it was last
+// verified to match, persisted in the TableSignatureFile.
+type VerifiedTableSignature struct {
+	Source TableSignature
+	Target TableSignature
+}
+
+// Tables split across targets, or whose target is not a database, have no
+// target signature. Tables without a modification timestamp column have no
+// signature either, as it would not change when rows are updated in place.
+func (v *IterativeVerifier) tableSignaturesSupported(table *TableSchema) bool {
+	if !v.targetSignaturesSupported(table) {
+		return false
+	}
+
+	_, exists := v.ModificationTimestampColumns[table.Name]
+	return exists
+}
+
+func (v *IterativeVerifier) targetSignaturesSupported(table *TableSchema) bool {
+	if !v.targetIsLive() {
+		return false
+	}
+
+	_, exists := v.TargetResolvers[table.Name]
+	return !exists
+}
+
+// Returns the current signature of the table, and whether it is the same as
+// when the table was last verified to match. The table is marked unchanged
+// from this point, until a binlog event touches it.
+func (v *IterativeVerifier) tableIsUnchanged(table *TableSchema) (VerifiedTableSignature, bool, error) {
+	v.tableSignaturesMutex.Lock()
+	if len(v.tableSignatures) == 0 {
+		if err := v.loadTableSignatures(); err != nil {
+			v.tableSignaturesMutex.Unlock()
+			return VerifiedTableSignature{}, false, err
+		}
+	}
+	delete(v.changedTables, table.String())
+	previous, exists := v.tableSignatures[table.String()]
+	v.tableSignaturesMutex.Unlock()
+
+	var signature VerifiedTableSignature
+	var err error
+	signature.Source, err = v.tableSignature(v.SourceDB, "source", table.Schema, table.Name, table, "")
+	if err != nil {
+		return signature, false, err
+	}
+
+	targetDb, targetTable := v.targetTableName(table)
+	signature.Target, err = v.tableSignature(v.TargetDB, "target", targetDb, targetTable, table, v.MergedTablePredicates[table.Name])
+	if err != nil {
+		return signature, false, err
+	}
+
+	return signature, exists && previous == signature, nil
+}
+
+// Returns the signature of the rows of the table matching the predicate, if
+// any.
+func (v *IterativeVerifier) tableSignature(db *sql.DB, side, schemaName, tableName string, table *TableSchema, where string) (TableSignature, error) {
+	quotedPaginationKey := quoteField(table.GetPaginationColumn().Name)
+	selects := []string{"COUNT(*)", fmt.Sprintf("COALESCE(MAX(%s), 0)", quotedPaginationKey)}
+	if column, exists := v.ModificationTimestampColumns[table.Name]; exists {
+		selects = append(selects, fmt.Sprintf("COALESCE(CAST(MAX(%s) AS CHAR), '')", quoteField(column)))
+	} else {
+		selects = append(selects, "''")
+	}
+
+	builder := sq.Select(selects...).From(QuotedTableNameFromString(schemaName, tableName))
+	if where != "" {
+		builder = builder.Where(fmt.Sprintf("(%s)", where))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return TableSignature{}, err
+	}
+
+	var signature TableSignature
+	err = v.readQueryRow(context.Background(), db, side, query, args, &signature.RowCount, &signature.MaxPaginationKey, &signature.MaxModificationTime)
+	return signature, err
+}
+
+// Persists the signature of the table after it was verified to match, unless
+// a binlog event touched the table during its verification.
+func (v *IterativeVerifier) recordTableSignature(table *TableSchema, signature VerifiedTableSignature) error {
+	v.tableSignaturesMutex.Lock()
+	defer v.tableSignaturesMutex.Unlock()
+
+	if v.changedTables[table.String()] {
+		return nil
+	}
+
+	v.tableSignatures[table.String()] = signature
+	return v.saveTableSignatures()
+}
+
+// Forgets the signature of the table, so that it is verified again on the
+// next run.
+func (v *IterativeVerifier) invalidateTableSignature(table *TableSchema) {
+	v.tableSignaturesMutex.Lock()
+	defer v.tableSignaturesMutex.Unlock()
+
+	v.changedTables[table.String()] = true
+	if _, exists := v.tableSignatures[table.String()]; !exists {
+		return
+	}
+
+	delete(v.tableSignatures, table.String())
+	if err := v.saveTableSignatures(); err != nil {
+		v.logger.WithError(err).Warn("failed to persist the invalidated table signature")
+	}
+}
+
+// Must be called with the tableSignaturesMutex held.
+func (v *IterativeVerifier) loadTableSignatures() error {
+	signatureBytes, err := ioutil.ReadFile(v.TableSignatureFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(signatureBytes, &v.tableSignatures)
+}
+
+// Must be called with the tableSignaturesMutex held.
+func (v *IterativeVerifier) saveTableSignatures() error {
+	signatureBytes, err := json.Marshal(v.tableSignatures)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := v.TableSignatureFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, signatureBytes, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, v.TableSignatureFile)
+}
@@ -0,0 +1,187 @@
+package ghostferry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The progress of VerifyBeforeCutover persisted in the StateFile. The keys
+// of the batches are encoded by the PKCodec of the ReverifyStore.
+type IterativeVerifierState struct {
+	CompletedTables []TableIdentifier
+	ReverifyStore   []PersistedReverifyBatch
+
+	// The batches of the last pass of VerifyDuringCutover that did not find
+	// the data to be correct, all reverified when the state is loaded.
+	CutoverBatches []PersistedReverifyBatch
+}
+
+func (v *IterativeVerifier) tableIsCompleted(table *TableSchema) bool {
+	v.completedTablesMutex.Lock()
+	defer v.completedTablesMutex.Unlock()
+
+	return v.completedTables[NewTableIdentifierFromSchemaTable(table)]
+}
+
+func (v *IterativeVerifier) markTableCompleted(table *TableSchema) error {
+	v.completedTablesMutex.Lock()
+	v.completedTables[NewTableIdentifierFromSchemaTable(table)] = true
+	v.completedTablesMutex.Unlock()
+
+	return v.writeState()
+}
+
+// Persists the progress of the verification in the StateFile, if any.
+func (v *IterativeVerifier) writeState() error {
+	if v.StateFile == "" {
+		return nil
+	}
+
+	var state IterativeVerifierState
+	var err error
+	state.ReverifyStore, err = v.reverifyStore.EncodeBatches(v.reverifyStore.Snapshot())
+	if err != nil {
+		return err
+	}
+
+	v.completedTablesMutex.Lock()
+	state.CompletedTables = make([]TableIdentifier, 0, len(v.completedTables))
+	for tableId, _ := range v.completedTables {
+		state.CompletedTables = append(state.CompletedTables, tableId)
+	}
+	v.completedTablesMutex.Unlock()
+
+	v.cutoverBatchesMutex.Lock()
+	state.CutoverBatches, err = v.reverifyStore.EncodeBatches(v.cutoverBatches)
+	v.cutoverBatchesMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	v.stateFileMutex.Lock()
+	defer v.stateFileMutex.Unlock()
+
+	// Write to a temporary file first so a crash while writing does not
+	// corrupt the previously persisted state.
+	tmpFile := v.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, stateBytes, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, v.StateFile)
+}
+
+// Returns the batches to reverify during cutover, with the index of each in
+// the cutoverBatches. The batches of a previous pass that was interrupted
+// are resumed, skipping those that were verified to match, followed by the
+// batches flushed from the store.
+func (v *IterativeVerifier) resumeCutoverBatches(flushedBatches []ReverifyBatch) ([]ReverifyBatch, []int, error) {
+	v.cutoverBatchesMutex.Lock()
+	resumedBatches := len(v.cutoverBatches) - len(v.completedCutoverBatches)
+	v.cutoverBatches = append(v.cutoverBatches, flushedBatches...)
+
+	batches := make([]ReverifyBatch, 0, len(v.cutoverBatches)-len(v.completedCutoverBatches))
+	indices := make([]int, 0, cap(batches))
+	for idx, batch := range v.cutoverBatches {
+		if v.completedCutoverBatches[idx] {
+			continue
+		}
+
+		batches = append(batches, batch)
+		indices = append(indices, idx)
+	}
+	v.cutoverBatchesMutex.Unlock()
+
+	if resumedBatches > 0 {
+		v.logger.WithField("batches", resumedBatches).Info("resuming the batches of an interrupted cutover verification")
+	}
+
+	return batches, indices, v.writeState()
+}
+
+// Records that the batch of the cutoverBatches was verified to match, so
+// that it is skipped if the cutover verification is resumed within the
+// process.
+func (v *IterativeVerifier) markCutoverBatchCompleted(idx int) {
+	v.cutoverBatchesMutex.Lock()
+	v.completedCutoverBatches[idx] = true
+	v.cutoverBatchesMutex.Unlock()
+}
+
+// Forgets the batches of the cutover verification once it completed.
+func (v *IterativeVerifier) clearCutoverBatches() error {
+	v.cutoverBatchesMutex.Lock()
+	v.cutoverBatches = nil
+	v.completedCutoverBatches = make(map[int]bool)
+	v.cutoverBatchesMutex.Unlock()
+
+	return v.writeState()
+}
+
+func (v *IterativeVerifier) loadState() error {
+	if v.StateFile == "" {
+		return nil
+	}
+
+	stateBytes, err := ioutil.ReadFile(v.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var state IterativeVerifierState
+	if err := json.Unmarshal(stateBytes, &state); err != nil {
+		return err
+	}
+
+	v.completedTablesMutex.Lock()
+	for _, tableId := range state.CompletedTables {
+		v.completedTables[tableId] = true
+	}
+	v.completedTablesMutex.Unlock()
+
+	for _, batch := range state.ReverifyStore {
+		table, err := v.reverifyTableSchema(batch.Table)
+		if err != nil {
+			return err
+		}
+
+		if err := v.reverifyStore.AddEncoded(table, batch, ReverifyOriginResumed); err != nil {
+			return err
+		}
+	}
+
+	var cutoverBatches []ReverifyBatch
+	for _, batch := range state.CutoverBatches {
+		decoded, err := v.reverifyStore.DecodeBatch(batch)
+		if err != nil {
+			return err
+		}
+
+		cutoverBatches = append(cutoverBatches, decoded)
+	}
+
+	v.cutoverBatchesMutex.Lock()
+	v.cutoverBatches = cutoverBatches
+	v.completedCutoverBatches = make(map[int]bool)
+	v.cutoverBatchesMutex.Unlock()
+
+	v.logger.WithFields(logrus.Fields{
+		"completed_tables": len(state.CompletedTables),
+		"rows":             v.reverifyStore.CurrentRowCount(),
+		"cutover_batches":  len(state.CutoverBatches),
+	}).Info("resuming iterative verification from persisted state")
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package ghostferry
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Encodes the keys of the rows pending reverification to and from the
+// strings they are persisted as in the StateFile of the IterativeVerifier, so
+// that the persisted state does not depend on the type of the keys. The
+// ReverifyStore only holds integer paginationKeys, so that its codec must
+// decode the keys to uint64.
+type PKCodec interface {
+	EncodePK(key interface{}) (string, error)
+	DecodePK(encoded string) (interface{}, error)
+}
+
+// Encodes uint64 keys as decimal strings, which unlike JSON numbers do not
+// lose precision above 2^53 when read by other tools.
+type IntegerPKCodec struct{}
+
+func (IntegerPKCodec) EncodePK(key interface{}) (string, error) {
+	integer, ok := key.(uint64)
+	if !ok {
+		return "", fmt.Errorf("cannot encode key of type %T as an integer", key)
+	}
+
+	return strconv.FormatUint(integer, 10), nil
+}
+
+func (IntegerPKCodec) DecodePK(encoded string) (interface{}, error) {
+	return strconv.ParseUint(encoded, 10, 64)
+}
+
+// A ReverifyBatch as persisted in the StateFile, with its keys encoded by the
+// PKCodec of the ReverifyStore.
+type PersistedReverifyBatch struct {
+	Table TableIdentifier
+	Keys  []string
+}
+
+// Encodes the keys of the batches with the PKCodec of the store.
+func (r *ReverifyStore) EncodeBatches(batches []ReverifyBatch) ([]PersistedReverifyBatch, error) {
+	persisted := make([]PersistedReverifyBatch, 0, len(batches))
+	for _, batch := range batches {
+		keys := make([]string, len(batch.PaginationKeys))
+		for idx, paginationKey := range batch.PaginationKeys {
+			key, err := r.PKCodec.EncodePK(paginationKey)
+			if err != nil {
+				return nil, err
+			}
+
+			keys[idx] = key
+		}
+
+		persisted = append(persisted, PersistedReverifyBatch{Table: batch.Table, Keys: keys})
+	}
+
+	return persisted, nil
+}
+
+// Decodes the keys of the batch with the PKCodec of the store. Keys that do
+// not decode to a paginationKey are rejected.
+func (r *ReverifyStore) DecodeBatch(batch PersistedReverifyBatch) (ReverifyBatch, error) {
+	paginationKeys := make([]uint64, len(batch.Keys))
+	for idx, encoded := range batch.Keys {
+		key, err := r.PKCodec.DecodePK(encoded)
+		if err != nil {
+			return ReverifyBatch{}, fmt.Errorf("invalid key %s of table %s.%s: %v", encoded, batch.Table.SchemaName, batch.Table.TableName, err)
+		}
+
+		paginationKey, ok := key.(uint64)
+		if !ok {
+			return ReverifyBatch{}, fmt.Errorf("key %s of table %s.%s decodes to %T, not a paginationKey", encoded, batch.Table.SchemaName, batch.Table.TableName, key)
+		}
+
+		paginationKeys[idx] = paginationKey
+	}
+
+	return ReverifyBatch{PaginationKeys: paginationKeys, Table: batch.Table}, nil
+}
+
+// Decodes the keys of the batch and adds them to the store for the table.
+func (r *ReverifyStore) AddEncoded(table *TableSchema, batch PersistedReverifyBatch, origin ReverifyOrigin) error {
+	decoded, err := r.DecodeBatch(batch)
+	if err != nil {
+		return err
+	}
+
+	for _, paginationKey := range decoded.PaginationKeys {
+		r.Add(ReverifyEntry{PaginationKey: paginationKey, Table: table, Origin: origin})
+	}
+
+	return nil
+}
@@ -39,6 +39,10 @@ type Progress struct {
 	// These are some variables that are only filled when CurrentState == done.
 	FinalBinlogPos mysql.Position
 
+	// The mismatches found by the IterativeVerifier during cutover. Only
+	// filled when the verification found the data to be incorrect.
+	VerificationMismatches *MismatchReport
+
 	// A best estimate on the speed at which the copying is taking place. If
 	// there are large gaps in the PaginationKey space, this probably will be inaccurate.
 	PaginationKeysPerSecond uint64
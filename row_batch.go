@@ -44,6 +44,29 @@ func (e *RowBatch) Fingerprints() map[uint64][]byte {
 }
 
 func (e *RowBatch) AsSQLQuery(schemaName, tableName string) (string, []interface{}, error) {
+	return e.asSQLQuery("INSERT IGNORE INTO ", schemaName, tableName)
+}
+
+// Like AsSQLQuery, but the existing rows with the same unique keys are
+// updated in place instead of being ignored. Unlike REPLACE, the rows are not
+// deleted and inserted again, which would cascade to the rows referencing
+// them and fire the delete triggers.
+func (e *RowBatch) AsUpsertSQLQuery(schemaName, tableName string) (string, []interface{}, error) {
+	query, args, err := e.asSQLQuery("INSERT INTO ", schemaName, tableName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	columns := quotedColumnNames(e.table)
+	updates := make([]string, len(columns))
+	for idx, column := range columns {
+		updates[idx] = column + "=VALUES(" + column + ")"
+	}
+
+	return query + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ","), args, nil
+}
+
+func (e *RowBatch) asSQLQuery(statement, schemaName, tableName string) (string, []interface{}, error) {
 	if err := verifyValuesHasTheSameLengthAsColumns(e.table, e.values...); err != nil {
 		return "", nil, err
 	}
@@ -53,7 +76,7 @@ func (e *RowBatch) AsSQLQuery(schemaName, tableName string) (string, []interface
 	valuesStr := "(" + strings.Repeat("?,", len(columns)-1) + "?)"
 	valuesStr = strings.Repeat(valuesStr+",", len(e.values)-1) + valuesStr
 
-	query := "INSERT IGNORE INTO " +
+	query := statement +
 		QuotedTableNameFromString(schemaName, tableName) +
 		" (" + strings.Join(columns, ",") + ") VALUES " + valuesStr
 
@@ -58,6 +58,11 @@ func (db DB) Begin() (*Tx, error) {
 	return &Tx{tx, db.Marginalia}, err
 }
 
+func (db DB) BeginTx(ctx context.Context, opts *sqlorig.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	return &Tx{tx, db.Marginalia}, err
+}
+
 func (tx Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sqlorig.Result, error) {
 	return tx.Tx.ExecContext(ctx, AnnotateStmt(query, tx.marginalia), args...)
 }
@@ -75,7 +80,7 @@ func (tx Tx) PrepareContext(ctx context.Context, query string) (*sqlorig.Stmt, e
 }
 
 func (tx Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sqlorig.Rows, error) {
-	return tx.Tx.QueryContext(ctx, query, args)
+	return tx.Tx.QueryContext(ctx, query, args...)
 }
 
 func (tx Tx) Query(query string, args ...interface{}) (*sqlorig.Rows, error) {
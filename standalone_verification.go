@@ -0,0 +1,76 @@
+package ghostferry
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Verifies the target against the source with the IterativeVerifier
+// configured by config.IterativeVerifierConfig, without a Ferry, such as to
+// check a database migrated earlier. Both the verification before and
+// during cutover are run to completion and the result of the latter is
+// returned.
+//
+// The binlog of the source is not tailed: the rows changed during the
+// verification are not reverified, so writes to the source should be stopped
+// beforehand, otherwise they may be reported as mismatches.
+func RunStandaloneVerification(config *Config) (VerificationResult, error) {
+	if err := config.ValidateConfig(); err != nil {
+		return VerificationResult{}, fmt.Errorf("failed to validate config: %v", err)
+	}
+
+	logger := logrus.WithField("tag", "standalone_verification")
+
+	sourceDB, err := config.Source.SqlDB(logger.WithField("dbname", "source"))
+	if err != nil {
+		return VerificationResult{}, fmt.Errorf("failed to connect to source database: %v", err)
+	}
+	defer sourceDB.Close()
+
+	targetDB, err := config.Target.SqlDB(logger.WithField("dbname", "target"))
+	if err != nil {
+		return VerificationResult{}, fmt.Errorf("failed to connect to target database: %v", err)
+	}
+	defer targetDB.Close()
+
+	tables, err := LoadTables(sourceDB, config.TableFilter, config.CompressedColumnsForVerification, config.IgnoredColumnsForVerification, config.CascadingPaginationColumnConfig)
+	if err != nil {
+		return VerificationResult{}, err
+	}
+
+	verifierConfig := config.IterativeVerifierConfig
+	verifierConfig.Dependencies = IterativeVerifierDependencies{
+		SourceDB: sourceDB,
+		TargetDB: targetDB,
+		// The streamer is never started, so that it never stops and no
+		// binlog event is received.
+		BinlogStreamer: &BinlogStreamer{
+			DB:          sourceDB,
+			DBConfig:    config.Source,
+			MyServerId:  config.MyServerId,
+			Filter:      config.CopyFilter,
+			TableSchema: tables,
+		},
+		TableSchemaCache: tables,
+		BatchSize:        config.DataIterationBatchSize,
+		ReadRetries:      config.DBReadRetries,
+		DatabaseRewrites: config.DatabaseRewrites,
+		TableRewrites:    config.TableRewrites,
+	}
+
+	if config.CopyFilter != nil {
+		verifierConfig.Dependencies.BuildSelect = config.CopyFilter.BuildSelect
+	}
+
+	verifier, err := NewIterativeVerifier(verifierConfig)
+	if err != nil {
+		return VerificationResult{}, err
+	}
+
+	if err := verifier.VerifyBeforeCutover(); err != nil {
+		return VerificationResult{}, err
+	}
+
+	return verifier.VerifyDuringCutover()
+}
@@ -40,6 +40,10 @@ type SerializableState struct {
 	BinlogVerifyStore                         BinlogVerifySerializedStore
 	LastStoredBinlogPositionForInlineVerifier mysql.Position
 	LastStoredBinlogPositionForTargetVerifier mysql.Position
+
+	// The mismatches found by the IterativeVerifier during cutover, dumped
+	// with the state for the error handler. Not used to resume.
+	VerificationMismatches *MismatchReport `json:",omitempty"`
 }
 
 func (s *SerializableState) MinSourceBinlogPosition() mysql.Position {
@@ -149,6 +149,30 @@ func (this *BinlogStreamerTestSuite) TestBinlogStreamerSetsQueryEventOnRowsEvent
 	this.Require().True(eventAsserted)
 }
 
+func (this *BinlogStreamerTestSuite) TestBinlogStreamerClosesStoppedOnExit() {
+	_, err := this.binlogStreamer.ConnectBinlogStreamerToMysql()
+	this.Require().Nil(err)
+
+	select {
+	case <-this.binlogStreamer.Stopped():
+		this.Fail("binlog streamer reported stopped before running")
+	default:
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		this.binlogStreamer.Run()
+	}()
+
+	this.binlogStreamer.FlushAndStop()
+	wg.Wait()
+
+	<-this.binlogStreamer.Stopped()
+	this.Require().Nil(this.binlogStreamer.Err())
+}
+
 func TestBinlogStreamerTestSuite(t *testing.T) {
 	testhelpers.SetupTest()
 	suite.Run(t, &BinlogStreamerTestSuite{GhostferryUnitTestSuite: &testhelpers.GhostferryUnitTestSuite{}})
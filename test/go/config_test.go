@@ -157,6 +157,265 @@ func (this *ConfigTestSuite) TestDefaultMarginalia() {
 	this.Require().Equal(ghostferry.DefaultMarginalia, this.config.Target.Marginalia)
 }
 
+func (this *ConfigTestSuite) TestValidatesIndexHints() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.IndexHints = map[string]string{
+		"table1": "FORCE INDEX (PRIMARY)",
+		"table2": "use key (idx_a, idx_b)",
+	}
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.IndexHints["table3"] = "FORCE INDEX (PRIMARY) WHERE 1=1"
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: invalid index hint for table table3: FORCE INDEX (PRIMARY) WHERE 1=1")
+}
+
+func (this *ConfigTestSuite) TestValidatesColumnSeverities() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.ColumnSeverities = map[string]map[string]string{
+		"table1": map[string]string{"notes": "low", "balance": "high"},
+	}
+	this.config.IterativeVerifierConfig.MinimumFailingSeverity = "medium"
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.MinimumFailingSeverity = "critical"
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: invalid MinimumFailingSeverity: unknown mismatch severity: critical")
+
+	this.config.IterativeVerifierConfig.MinimumFailingSeverity = ""
+	this.config.IterativeVerifierConfig.ColumnSeverities["table1"]["notes"] = "lowest"
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: invalid severity for column notes of table table1: unknown mismatch severity: lowest")
+}
+
+func (this *ConfigTestSuite) TestValidatesTargetCircuitBreaker() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.TargetCircuitBreakerMaxErrorRate = 0.5
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+	this.Require().Equal(100, this.config.IterativeVerifierConfig.TargetCircuitBreakerWindow)
+
+	this.config.IterativeVerifierConfig.TargetCircuitBreakerMaxErrorRate = 1.5
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: TargetCircuitBreakerMaxErrorRate must be between 0 and 1, not 1.5")
+}
+
+func (this *ConfigTestSuite) TestValidatesReverifyFailurePolicy() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.ReverifyFailurePolicy = "best_effort"
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.ReverifyFailurePolicy = "ignore"
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: unknown reverify failure policy: ignore")
+}
+
+func (this *ConfigTestSuite) TestValidatesAggregates() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.Aggregates = map[string][]string{
+		"test_table_1": []string{"MAX(id)", "COUNT(*)"},
+	}
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.Aggregates["test_table_1"] = []string{"MEDIAN(id)"}
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: unknown aggregate function in MEDIAN(id), must be one of SUM, MIN, MAX or COUNT")
+}
+
+func (this *ConfigTestSuite) TestValidatesVerifyWhere() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.VerifyWhere = map[string]string{
+		"test_table_1": "(status = 'active' OR data LIKE '%;--%')",
+	}
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	for predicate, expectedErr := range map[string]string{
+		"":                              "predicate must not be empty",
+		"id > 1; DROP TABLE test_table": "statement separators are not allowed",
+		"id > 1 -- comment":             "comments are not allowed",
+		"(id > 1":                       "unbalanced parentheses",
+		"data = 'foo":                   "unterminated quoted string",
+	} {
+		this.config.IterativeVerifierConfig.VerifyWhere["test_table_1"] = predicate
+		err = this.config.ValidateConfig()
+		this.Require().EqualError(err, "IterativeVerifierConfig invalid: invalid VerifyWhere for table test_table_1: "+expectedErr)
+	}
+}
+
+func (this *ConfigTestSuite) TestValidatesReadHints() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.SourceReadHint = "/*+ read_from_replica */"
+	this.config.IterativeVerifierConfig.TargetReadHint = "/* route=replica */"
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	for hint, expectedErr := range map[string]string{
+		"read_from_replica":                "hint must be a /* ... */ comment",
+		"/* replica */ SELECT 1":           "hint must be a /* ... */ comment",
+		"/* replica */ OR 1 /* replica */": "hint must be a single comment",
+	} {
+		this.config.IterativeVerifierConfig.SourceReadHint = hint
+		err = this.config.ValidateConfig()
+		this.Require().EqualError(err, "IterativeVerifierConfig invalid: invalid SourceReadHint: "+expectedErr)
+	}
+}
+
+func (this *ConfigTestSuite) TestValidatesMaxPreparedStatementsPerDB() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.MaxPreparedStatementsPerDB = 8
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.MaxPreparedStatementsPerDB = -1
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: MaxPreparedStatementsPerDB must not be negative, not -1")
+}
+
+func (this *ConfigTestSuite) TestValidatesExaminedRowsWarningRatio() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.ExaminedRowsWarningRatio = 10
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.ExaminedRowsWarningRatio = -1
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: ExaminedRowsWarningRatio must not be negative, not -1")
+}
+
+func (this *ConfigTestSuite) TestValidatesTableTimeBudget() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.TableTimeBudget = "10m"
+	this.config.IterativeVerifierConfig.TableTimeBudgetSampleRate = 0.1
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.TableTimeBudgetSampleRate = 2
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: TableTimeBudgetSampleRate must be between 0 and 1, not 2")
+
+	this.config.IterativeVerifierConfig.TableTimeBudgetSampleRate = 0
+	this.config.IterativeVerifierConfig.TableTimeBudget = "10"
+	err = this.config.ValidateConfig()
+	this.Require().NotNil(err)
+}
+
+func (this *ConfigTestSuite) TestValidatesShards() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.ShardCount = 4
+	this.config.IterativeVerifierConfig.ShardIndex = 3
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.ShardIndex = 4
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: ShardIndex must be between 0 and ShardCount 4, not 4")
+
+	this.config.IterativeVerifierConfig.ShardCount = -1
+	this.config.IterativeVerifierConfig.ShardIndex = 0
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: ShardCount must not be negative, not -1")
+}
+
+func (this *ConfigTestSuite) TestValidatesGtidWaitTimeout() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.WaitForSourceGtid = true
+	this.config.IterativeVerifierConfig.GtidWaitTimeout = "30s"
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.GtidWaitTimeout = "-30s"
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: GtidWaitTimeout must not be negative, not -30s")
+}
+
+func (this *ConfigTestSuite) TestValidatesBatchTimeout() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.BatchTimeout = "1m"
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.BatchTimeout = "1"
+	err = this.config.ValidateConfig()
+	this.Require().NotNil(err)
+}
+
+func (this *ConfigTestSuite) TestValidatesMergedTablePredicates() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.MergedTablePredicates = map[string]string{"table1": "origin = 'table1'"}
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.MergedTablePredicates = map[string]string{"table1": "origin = 'table1"}
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: invalid MergedTablePredicates for table table1: unterminated quoted string")
+}
+
+func (this *ConfigTestSuite) TestValidatesCurrentVersionPredicates() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.CurrentVersionPredicates = map[string]string{"table1": "schema_version = 2"}
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.CurrentVersionPredicates = map[string]string{"table1": "schema_version = 2; DROP TABLE table1"}
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: invalid CurrentVersionPredicates for table table1: statement separators are not allowed")
+}
+
+func (this *ConfigTestSuite) TestValidatesFingerprintHashFunction() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	for _, hashFunction := range []string{"", "MD5", "CRC32"} {
+		this.config.IterativeVerifierConfig.FingerprintHashFunction = hashFunction
+		err := this.config.ValidateConfig()
+		this.Require().Nil(err)
+	}
+
+	this.config.IterativeVerifierConfig.FingerprintHashFunction = "SHA1"
+	err := this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: FingerprintHashFunction must be MD5 or CRC32, not SHA1")
+}
+
+func (this *ConfigTestSuite) TestValidatesFingerprintFormat() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	for _, format := range []int{0, 1, 2} {
+		this.config.IterativeVerifierConfig.FingerprintFormat = format
+		err := this.config.ValidateConfig()
+		this.Require().Nil(err)
+	}
+
+	this.config.IterativeVerifierConfig.FingerprintFormat = 3
+	err := this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: FingerprintFormat must be 1 or 2, not 3")
+}
+
+func (this *ConfigTestSuite) TestValidatesRowFingerprintAlias() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.RowFingerprintAlias = "fingerprint_2"
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.RowFingerprintAlias = "fingerprint FROM"
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: RowFingerprintAlias must be an identifier of letters, digits and underscores, not fingerprint FROM")
+}
+
+func (this *ConfigTestSuite) TestValidatesColumnTransformations() {
+	this.config.VerifierType = ghostferry.VerifierTypeIterative
+	this.config.IterativeVerifierConfig.ColumnTransformations = map[string]map[string]string{
+		"test_table_1": map[string]string{"data": "UPPER(`data`)"},
+	}
+	err := this.config.ValidateConfig()
+	this.Require().Nil(err)
+
+	this.config.IterativeVerifierConfig.ColumnTransformations["test_table_1"]["data"] = "UPPER(`data`); DROP TABLE test_table_1"
+	err = this.config.ValidateConfig()
+	this.Require().EqualError(err, "IterativeVerifierConfig invalid: invalid ColumnTransformations for column test_table_1.data: statement separators are not allowed")
+}
+
 func TestConfig(t *testing.T) {
 	testhelpers.SetupTest()
 	suite.Run(t, new(ConfigTestSuite))
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"math"
 	"testing"
 
 	"github.com/Shopify/ghostferry"
@@ -370,6 +371,22 @@ func (this *DMLEventsTestSuite) TestNoRowsQueryEvent() {
 	this.Require().Equal("", annotation)
 }
 
+func (this *DMLEventsTestSuite) TestRowDataGetUint64HandlesUnsignedValues() {
+	row := ghostferry.RowData{uint64(math.MaxUint64), []byte("18446744073709551615"), int64(42), uint32(7), int64(-1), "42"}
+
+	for idx, expected := range []uint64{math.MaxUint64, math.MaxUint64, 42, 7} {
+		value, err := row.GetUint64(idx)
+		this.Require().Nil(err)
+		this.Require().Equal(expected, value)
+	}
+
+	_, err := row.GetUint64(4)
+	this.Require().NotNil(err)
+
+	_, err = row.GetUint64(5)
+	this.Require().NotNil(err)
+}
+
 func TestDMLEventsTestSuite(t *testing.T) {
 	suite.Run(t, new(DMLEventsTestSuite))
 }
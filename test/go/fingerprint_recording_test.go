@@ -0,0 +1,62 @@
+package test
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shopify/ghostferry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayFingerprintRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fingerprint_recording")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "records.jsonl")
+	recorder := ghostferry.NewFingerprintRecordFileWriter(path)
+
+	assert.Nil(t, recorder(ghostferry.FingerprintRecord{
+		Schema:         "gftest",
+		Table:          "table1",
+		PaginationKeys: []uint64{1, 2, 3},
+		Queries:        []ghostferry.FingerprintQuery{{Side: "source", Query: "SELECT 1", Args: []string{"1"}}},
+		SourceHashes:   map[uint64]string{1: "aaa", 2: "bbb", 3: "ccc"},
+		TargetHashes:   map[uint64]string{1: "aaa", 2: "xxx"},
+		Mismatches:     []uint64{2, 3},
+	}))
+	assert.Nil(t, recorder(ghostferry.FingerprintRecord{
+		Schema:         "gftest",
+		Table:          "table2",
+		PaginationKeys: []uint64{4, math.MaxUint64},
+		SourceHashes:   map[uint64]string{4: "ddd", math.MaxUint64: "eee"},
+		TargetHashes:   map[uint64]string{4: "ddd", math.MaxUint64: "eee"},
+		Mismatches:     []uint64{},
+	}))
+
+	// The paginationKeys are encoded as strings, which are not rounded by
+	// the JSON decoders that parse numbers as doubles.
+	recordsBytes, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(recordsBytes), `"PaginationKeys":["4","18446744073709551615"]`)
+
+	file, err := os.Open(path)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	replayed := make(map[string][]uint64)
+	err = ghostferry.ReplayFingerprintRecords(file, func(record ghostferry.FingerprintRecord, mismatches []uint64) error {
+		assert.Equal(t, record.Mismatches, mismatches)
+		if record.Table == "table2" {
+			assert.Equal(t, []uint64{4, math.MaxUint64}, record.PaginationKeys)
+		}
+		replayed[record.Table] = mismatches
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]uint64{"table1": []uint64{2, 3}, "table2": []uint64{}}, replayed)
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/ghostferry"
+	"github.com/siddontang/go-mysql/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFingerprints(t *testing.T) {
+	fingerprints, err := ghostferry.ReadFingerprints(strings.NewReader("1,e80b5017098950fc58aad83c8c14978e\n42,0cc175b9c0f1b6a831c399e269772661\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64][]byte{
+		1:  []byte("e80b5017098950fc58aad83c8c14978e"),
+		42: []byte("0cc175b9c0f1b6a831c399e269772661"),
+	}, fingerprints)
+
+	_, err = ghostferry.ReadFingerprints(strings.NewReader("a,e80b5017098950fc58aad83c8c14978e\n"))
+	assert.NotNil(t, err)
+
+	_, err = ghostferry.ReadFingerprints(strings.NewReader("1\n"))
+	assert.NotNil(t, err)
+}
+
+func TestFingerprintFileSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fingerprint_source")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "table1.csv")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("1,aaa\n2,bbb\n3,ccc\n"), 0644))
+
+	source := &ghostferry.FingerprintFileSource{Files: map[string]string{"table1": path}}
+
+	table1 := &ghostferry.TableSchema{Table: &schema.Table{Schema: "gftest", Name: "table1"}}
+	hashes, err := source.GetHashes(context.Background(), table1, []uint64{1, 3, 4})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64][]byte{1: []byte("aaa"), 3: []byte("ccc")}, hashes)
+
+	table2 := &ghostferry.TableSchema{Table: &schema.Table{Schema: "gftest", Name: "table2"}}
+	_, err = source.GetHashes(context.Background(), table2, []uint64{1})
+	assert.EqualError(t, err, "no fingerprint file for table gftest.table2")
+}
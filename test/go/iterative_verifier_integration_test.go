@@ -1,14 +1,22 @@
 package test
 
 import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sort"
 	"testing"
+	"time"
 
 	sql "github.com/Shopify/ghostferry/sqlwrapper"
 
 	"github.com/Shopify/ghostferry"
 	"github.com/Shopify/ghostferry/testhelpers"
+	"github.com/go-sql-driver/mysql"
 	"github.com/siddontang/go-mysql/schema"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHashesSql(t *testing.T) {
@@ -25,6 +33,458 @@ func TestHashesSql(t *testing.T) {
 	}
 }
 
+func TestHashesSqlWithWhere(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "data"}}
+	options := ghostferry.FingerprintOptions{Where: "data = 'a' OR data = 'b'"}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1, 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(`data`, 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?,?) AND (data = 'a' OR data = 'b') ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithNullEquivalentValues(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "data"}}
+	options := ghostferry.FingerprintOptions{NullEquivalentValues: map[string]string{"data": "it's"}}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(COALESCE(`data`, 'it''s'), 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithBinaryColumns(t *testing.T) {
+	columns := []schema.TableColumn{
+		schema.TableColumn{Name: "id", Type: schema.TYPE_NUMBER, RawType: "bigint(20)"},
+		schema.TableColumn{Name: "bin", Type: schema.TYPE_STRING, RawType: "binary(16)"},
+		schema.TableColumn{Name: "varbin", Type: schema.TYPE_STRING, RawType: "varbinary(255)"},
+		schema.TableColumn{Name: "str", Type: schema.TYPE_STRING, RawType: "varchar(255)"},
+	}
+
+	sql, _, err := ghostferry.GetMd5HashesSql("gftest", "test_table", "id", columns, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(CAST(`bin` AS BINARY), 'NULL')),"+
+		"MD5(COALESCE(CAST(`varbin` AS BINARY), 'NULL')),MD5(COALESCE(`str`, 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithIndexHint(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}}
+	options := ghostferry.FingerprintOptions{IndexHint: "FORCE INDEX (PRIMARY)"}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` FORCE INDEX (PRIMARY) WHERE `id` IN (?) ORDER BY `id`", sql)
+
+	sql, _, err = ghostferry.GetMd5BatchChecksumSql("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Contains(t, sql, "FROM `gftest`.`test_table` FORCE INDEX (PRIMARY) WHERE `id` IN (?)")
+}
+
+func TestHashesSqlWithAdditionalExpressions(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}}
+	options := ghostferry.FingerprintOptions{AdditionalExpressions: []string{"CONCAT(`a`, ' ', `b`)"}}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(CONCAT(`a`, ' ', `b`), 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithColumnGroups(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "a"}, schema.TableColumn{Name: "b"}}
+	options := ghostferry.FingerprintOptions{ColumnGroupSize: 2}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(`a`, 'NULL')))),MD5(CONCAT(MD5(COALESCE(`b`, 'NULL')))))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+
+	// Tables narrower than a group are fingerprinted as without groups.
+	options.ColumnGroupSize = 3
+	groupedSql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+	assert.Nil(t, err)
+
+	ungroupedSql, _, err := ghostferry.GetMd5HashesSql("gftest", "test_table", "id", columns, []uint64{1})
+	assert.Nil(t, err)
+	assert.Equal(t, ungroupedSql, groupedSql)
+}
+
+func TestHashesSqlWithFingerprintFormatV2(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "data"}}
+	options := ghostferry.FingerprintOptions{Format: ghostferry.FingerprintFormatV2, AdditionalExpressions: []string{"UPPER(`data`)"}}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(CONCAT('0', `id`), '1')),MD5(COALESCE(CONCAT('0', `data`), '1')),MD5(COALESCE(CONCAT('0', UPPER(`data`)), '1')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithCrc32(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "data"}}
+	options := ghostferry.FingerprintOptions{HashFunction: ghostferry.FingerprintHashCRC32}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, LPAD(HEX(CRC32(CONCAT(LPAD(HEX(CRC32(COALESCE(`id`, 'NULL'))), 8, '0'),LPAD(HEX(CRC32(COALESCE(`data`, 'NULL'))), 8, '0')))), 8, '0') "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+
+	sql, _, err = ghostferry.GetMd5ColumnHashesSql("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, LPAD(HEX(CRC32(`id`)), 8, '0'), LPAD(HEX(CRC32(`data`)), 8, '0') "+
+		"FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithRowFingerprintColumn(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "row_fingerprint"}}
+
+	_, _, err := ghostferry.GetMd5HashesSql("gftest", "test_table", "id", columns, []uint64{1})
+	assert.EqualError(t, err, "row fingerprint alias row_fingerprint collides with column row_fingerprint, see RowFingerprintAlias")
+
+	options := ghostferry.FingerprintOptions{RowFingerprintAlias: "fingerprint"}
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(`row_fingerprint`, 'NULL')))) "+
+		"AS fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+
+	options.RowFingerprintAlias = "ROW_FINGERPRINT"
+	_, _, err = ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+	assert.EqualError(t, err, "row fingerprint alias ROW_FINGERPRINT collides with column row_fingerprint, see RowFingerprintAlias")
+}
+
+func TestHashesSqlWithGeometryColumns(t *testing.T) {
+	columns := []schema.TableColumn{
+		schema.TableColumn{Name: "id"},
+		schema.TableColumn{Name: "location", Type: schema.TYPE_STRING, RawType: "point"},
+		schema.TableColumn{Name: "area", Type: schema.TYPE_STRING, RawType: "geometry"},
+	}
+
+	sql, _, err := ghostferry.GetMd5HashesSql("gftest", "test_table", "id", columns, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),"+
+		"MD5(COALESCE(CONCAT(ST_SRID(`location`), ':', ST_AsText(`location`)), 'NULL')),"+
+		"MD5(COALESCE(CONCAT(ST_SRID(`area`), ':', ST_AsText(`area`)), 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithWidenedIntegerColumns(t *testing.T) {
+	columns := []schema.TableColumn{
+		schema.TableColumn{Name: "id", Type: schema.TYPE_NUMBER, RawType: "bigint(20) unsigned", IsUnsigned: true},
+		schema.TableColumn{Name: "count", Type: schema.TYPE_NUMBER, RawType: "int(5) unsigned zerofill", IsUnsigned: true},
+		schema.TableColumn{Name: "delta", Type: schema.TYPE_NUMBER, RawType: "int(11)"},
+		schema.TableColumn{Name: "year", Type: schema.TYPE_NUMBER, RawType: "year(4)"},
+	}
+	options := ghostferry.FingerprintOptions{
+		WidenedColumns: map[string]struct{}{"count": struct{}{}, "delta": struct{}{}, "year": struct{}{}},
+	}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(CAST(`count` AS UNSIGNED), 'NULL')),"+
+		"MD5(COALESCE(CAST(`delta` AS SIGNED), 'NULL')),MD5(COALESCE(`year`, 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithWidenedTextColumns(t *testing.T) {
+	columns := []schema.TableColumn{
+		schema.TableColumn{Name: "id"},
+		schema.TableColumn{Name: "name", Type: schema.TYPE_STRING, RawType: "varchar(50)"},
+		schema.TableColumn{Name: "body", Type: schema.TYPE_STRING, RawType: "mediumtext"},
+		schema.TableColumn{Name: "payload", Type: schema.TYPE_STRING, RawType: "blob"},
+		schema.TableColumn{Name: "title", Type: schema.TYPE_STRING, RawType: "varchar(50)"},
+	}
+	options := ghostferry.FingerprintOptions{
+		WidenedColumns: map[string]struct{}{"name": struct{}{}, "body": struct{}{}, "payload": struct{}{}},
+	}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(CONVERT(`name` USING utf8mb4), 'NULL')),"+
+		"MD5(COALESCE(CONVERT(`body` USING utf8mb4), 'NULL')),MD5(COALESCE(`payload`, 'NULL')),MD5(COALESCE(`title`, 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithCharColumns(t *testing.T) {
+	columns := []schema.TableColumn{
+		schema.TableColumn{Name: "id"},
+		schema.TableColumn{Name: "code", Type: schema.TYPE_STRING, RawType: "char(5)"},
+		schema.TableColumn{Name: "data", Type: schema.TYPE_STRING, RawType: "varchar(255)"},
+	}
+
+	sql, _, err := ghostferry.GetMd5HashesSql("gftest", "test_table", "id", columns, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(RTRIM(`code`), 'NULL')),MD5(COALESCE(`data`, 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithColumnTransformations(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "phone"}}
+	options := ghostferry.FingerprintOptions{
+		ColumnTransformations: map[string]string{"phone": "REPLACE(`phone`, '-', '')"},
+	}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE((REPLACE(`phone`, '-', '')), 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithLowercasedColumns(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "data"}}
+	options := ghostferry.FingerprintOptions{
+		LowercasedColumns:    map[string]struct{}{"data": struct{}{}},
+		NullEquivalentValues: map[string]string{"data": ""},
+	}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(COALESCE(LOWER(`data`), ''), 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestHashesSqlWithUncompressedColumns(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "data", Type: schema.TYPE_STRING, RawType: "varbinary(255)"}}
+	options := ghostferry.FingerprintOptions{
+		UncompressedColumns: map[string]struct{}{"data": struct{}{}},
+	}
+
+	sql, _, err := ghostferry.GetMd5HashesSqlWithOptions("gftest", "test_table", "id", columns, options, []uint64{1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(UNCOMPRESS(`data`), 'NULL')))) "+
+		"AS row_fingerprint FROM `gftest`.`test_table` WHERE `id` IN (?) ORDER BY `id`", sql)
+}
+
+func TestExistingPaginationKeysSql(t *testing.T) {
+	paginationKeys := []uint64{1, 5, 42}
+
+	sql, args, err := ghostferry.GetExistingPaginationKeysSql("gftest", "test_table", "id", ghostferry.FingerprintOptions{Where: "status = 'active'"}, paginationKeys)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id` FROM `gftest`.`test_table` WHERE `id` IN (?,?,?) AND (status = 'active')", sql)
+	assert.Equal(t, []interface{}{uint64(1), uint64(5), uint64(42)}, args)
+}
+
+func TestWindowChecksumSql(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "data"}}
+
+	sql, args, err := ghostferry.GetMd5WindowChecksumSql("gftest", "test_table", "id", columns, ghostferry.FingerprintOptions{}, 10, 20)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT COUNT(*), COALESCE(BIT_XOR(CAST(CONV(SUBSTRING(MD5(CONCAT(MD5(COALESCE(`id`, 'NULL')),MD5(COALESCE(`data`, 'NULL')))), 1, 16), 16, 10) AS UNSIGNED)), 0) "+
+		"FROM `gftest`.`test_table` WHERE `id` > ? AND `id` <= ?", sql)
+	assert.Equal(t, []interface{}{uint64(10), uint64(20)}, args)
+}
+
+func TestAggregatesSql(t *testing.T) {
+	aggregates := []ghostferry.Aggregate{
+		ghostferry.Aggregate{Function: "SUM", Column: "amount"},
+		ghostferry.Aggregate{Function: "COUNT", Column: "*"},
+	}
+
+	sql, args, err := ghostferry.GetAggregatesSql("gftest", "test_table", aggregates, ghostferry.FingerprintOptions{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT SUM(`amount`), COUNT(*) FROM `gftest`.`test_table`", sql)
+	assert.Empty(t, args)
+
+	sql, _, err = ghostferry.GetAggregatesSql("gftest", "test_table", aggregates, ghostferry.FingerprintOptions{Where: "origin = 'a'"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT SUM(`amount`), COUNT(*) FROM `gftest`.`test_table` WHERE (origin = 'a')", sql)
+}
+
+func TestDistributionSql(t *testing.T) {
+	sql, args, err := ghostferry.GetDistributionSql("gftest", "test_table", "status", ghostferry.FingerprintOptions{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `status`, COUNT(*) FROM `gftest`.`test_table` GROUP BY `status`", sql)
+	assert.Empty(t, args)
+
+	sql, _, err = ghostferry.GetDistributionSql("gftest", "test_table", "status", ghostferry.FingerprintOptions{Where: "id > 10"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `status`, COUNT(*) FROM `gftest`.`test_table` WHERE (id > 10) GROUP BY `status`", sql)
+}
+
+func TestMaxPaginationKeySql(t *testing.T) {
+	sql, args, err := ghostferry.GetMaxPaginationKeySql("gftest", "test_table", "id", ghostferry.FingerprintOptions{Where: "status = 'active'"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT COALESCE(MAX(`id`), 0) FROM `gftest`.`test_table` WHERE (status = 'active')", sql)
+	assert.Empty(t, args)
+
+	sql, _, err = ghostferry.GetMaxPaginationKeySql("gftest", "test_table", "id", ghostferry.FingerprintOptions{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT COALESCE(MAX(`id`), 0) FROM `gftest`.`test_table`", sql)
+
+	sql, args, err = ghostferry.GetRowsAbovePaginationKeySql("gftest", "test_table", "id", ghostferry.FingerprintOptions{}, 42)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT COUNT(*), COALESCE(MAX(`id`), 0) FROM `gftest`.`test_table` WHERE `id` > ?", sql)
+	assert.Equal(t, []interface{}{uint64(42)}, args)
+}
+
+func TestParseAggregate(t *testing.T) {
+	aggregate, err := ghostferry.ParseAggregate("sum(amount)")
+	require.Nil(t, err)
+	assert.Equal(t, ghostferry.Aggregate{Function: "SUM", Column: "amount"}, aggregate)
+
+	aggregate, err = ghostferry.ParseAggregate("COUNT(*)")
+	require.Nil(t, err)
+	assert.Equal(t, ghostferry.Aggregate{Function: "COUNT", Column: "*"}, aggregate)
+
+	_, err = ghostferry.ParseAggregate("AVG(amount)")
+	assert.EqualError(t, err, "unknown aggregate function in AVG(amount), must be one of SUM, MIN, MAX or COUNT")
+
+	_, err = ghostferry.ParseAggregate("MAX(*)")
+	assert.EqualError(t, err, "invalid column in aggregate: MAX(*)")
+
+	_, err = ghostferry.ParseAggregate("amount")
+	assert.EqualError(t, err, "invalid aggregate: amount")
+}
+
+func TestColumnHashesSql(t *testing.T) {
+	columns := []schema.TableColumn{schema.TableColumn{Name: "id"}, schema.TableColumn{Name: "data"}}
+	options := ghostferry.FingerprintOptions{AdditionalExpressions: []string{"`full_name`"}}
+
+	sql, _, err := ghostferry.GetMd5ColumnHashesSql("gftest", "test_table", "id", columns, options, []uint64{1, 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, MD5(`id`), MD5(`data`), MD5(`full_name`) "+
+		"FROM `gftest`.`test_table` WHERE `id` IN (?,?) ORDER BY `id`", sql)
+}
+
+func TestNewMismatchReport(t *testing.T) {
+	table1 := ghostferry.TableIdentifier{SchemaName: "gftest", TableName: "table1"}
+	report := ghostferry.NewMismatchReport(ghostferry.VerificationResult{
+		DataCorrect:     false,
+		Message:         "verification failed",
+		IncorrectTables: []string{"gftest.table1", "gftest.table2"},
+		Mismatches: []ghostferry.VerificationMismatch{
+			ghostferry.NewVerificationMismatch(table1, 1),
+			ghostferry.NewVerificationMismatch(table1, 42),
+		},
+	})
+
+	assert.Equal(t, "verification failed", report.Message)
+	assert.Equal(t, 2, len(report.Tables))
+	assert.Equal(t, []uint64{1, 42}, sortedUint64Keys(report.Tables["gftest.table1"]))
+	assert.Equal(t, ghostferry.NewVerificationMismatch(table1, 42), report.Tables["gftest.table1"][42])
+	assert.Empty(t, report.Tables["gftest.table2"])
+}
+
+func sortedUint64Keys(m map[uint64]ghostferry.VerificationMismatch) []uint64 {
+	keys := make([]uint64, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := ghostferry.NewCircuitBreaker(4, 0.5)
+	queryErr := driver.ErrBadConn
+
+	// The breaker does not open before a full window of queries.
+	breaker.Record(queryErr)
+	breaker.Record(queryErr)
+	breaker.Record(queryErr)
+	assert.Nil(t, breaker.Allow())
+
+	breaker.Record(nil)
+	assert.Equal(t, ghostferry.ErrTargetUnhealthy, breaker.Allow())
+
+	// The breaker stays open once the error rate has been exceeded.
+	breaker.Record(nil)
+	assert.Equal(t, ghostferry.ErrTargetUnhealthy, breaker.Allow())
+}
+
+func TestCircuitBreakerToleratesErrorRate(t *testing.T) {
+	breaker := ghostferry.NewCircuitBreaker(4, 0.5)
+	queryErr := driver.ErrBadConn
+
+	for i := 0; i < 10; i++ {
+		breaker.Record(queryErr)
+		breaker.Record(nil)
+	}
+	assert.Nil(t, breaker.Allow())
+
+	breaker.Record(queryErr)
+	breaker.Record(queryErr)
+	assert.Equal(t, ghostferry.ErrTargetUnhealthy, breaker.Allow())
+}
+
+func TestCircuitBreakerOnlyCountsConnectionAndTimeoutErrors(t *testing.T) {
+	breaker := ghostferry.NewCircuitBreaker(2, 0.5)
+
+	// A healthy target returns SQL errors too.
+	breaker.Record(&mysql.MySQLError{Number: 1054, Message: "Unknown column 'data' in 'field list'"})
+	breaker.Record(errors.New("sql: Scan error on column index 0"))
+	assert.Nil(t, breaker.Allow())
+
+	breaker.Record(&mysql.MySQLError{Number: 3024, Message: "Query execution was interrupted, maximum statement execution time exceeded"})
+	breaker.Record(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+	assert.Equal(t, ghostferry.ErrTargetUnhealthy, breaker.Allow())
+}
+
+func TestQueryLimiterBlocksUntilReleased(t *testing.T) {
+	limiter := ghostferry.NewQueryLimiter(1)
+	limiter.Acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.Acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired a slot of a full limiter")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.Release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("did not acquire the released slot")
+	}
+}
+
+func TestQueryLimiterAcquireContextFailsOnceDone(t *testing.T) {
+	limiter := ghostferry.NewQueryLimiter(1)
+	limiter.Acquire()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, limiter.AcquireContext(ctx))
+
+	limiter.Release()
+	assert.Nil(t, limiter.AcquireContext(context.Background()))
+}
+
 func TestVerificationFailsDeletedRow(t *testing.T) {
 	ferry := testhelpers.NewTestFerry()
 	iterativeVerifier := &ghostferry.IterativeVerifier{}
@@ -240,6 +700,41 @@ func TestVerificationPasses(t *testing.T) {
 	assert.True(t, ran)
 }
 
+func TestPanicInBinlogEventListenerIsReported(t *testing.T) {
+	ferry := testhelpers.NewTestFerry()
+	errHandler := &testhelpers.ErrorHandler{}
+	ferry.ErrorHandler = errHandler
+
+	iterativeVerifier := &ghostferry.IterativeVerifier{
+		ReverifyKeyExtractor: func(ev ghostferry.DMLEvent) ([]uint64, error) {
+			panic("malformed event")
+		},
+	}
+
+	testcase := &testhelpers.IntegrationTestCase{
+		T:           t,
+		SetupAction: setupSingleTableDatabase,
+		Ferry:       ferry,
+		AfterRowCopyIsComplete: func(ferry *testhelpers.TestFerry, sourceDB, targetDB *sql.DB) {
+			setupIterativeVerifierFromFerry(iterativeVerifier, ferry.Ferry)
+
+			err := iterativeVerifier.Initialize()
+			testhelpers.PanicIfError(err)
+
+			err = iterativeVerifier.VerifyBeforeCutover()
+			testhelpers.PanicIfError(err)
+		},
+		BeforeStoppingBinlogStreaming: func(ferry *testhelpers.TestFerry, sourceDB, targetDB *sql.DB) {
+			ensureTestRowsAreReverified(ferry)
+		},
+	}
+
+	testcase.Run()
+
+	require.NotNil(t, errHandler.LastError)
+	require.Equal(t, "failed to add binlog events to the reverify store: malformed event", errHandler.LastError.Error())
+}
+
 func setupIterativeVerifierFromFerry(v *ghostferry.IterativeVerifier, f *ghostferry.Ferry) {
 	v.CursorConfig = &ghostferry.CursorConfig{
 		DB:          f.SourceDB,
@@ -292,3 +787,19 @@ func deleteTestRowsToTriggerFailure(ferry *testhelpers.TestFerry) {
 	_, err = ferry.Ferry.TargetDB.Exec("DELETE FROM gftest.table1 WHERE id = \"43\"")
 	testhelpers.PanicIfError(err)
 }
+
+func TestNewIterativeVerifierValidatesItsArguments(t *testing.T) {
+	_, err := ghostferry.NewIterativeVerifier(ghostferry.IterativeVerifierConfig{DutyCycle: 2})
+	assert.EqualError(t, err, "IterativeVerifierConfig invalid: DutyCycle must be between 0 and 1, not 2")
+
+	_, err = ghostferry.NewIterativeVerifier(ghostferry.IterativeVerifierConfig{})
+	assert.EqualError(t, err, "TableSchemaCache must not be nil")
+
+	verifier, err := ghostferry.NewIterativeVerifier(ghostferry.IterativeVerifierConfig{
+		Dependencies: ghostferry.IterativeVerifierDependencies{
+			TableSchemaCache: ghostferry.TableSchemaCache{},
+		},
+	})
+	assert.EqualError(t, err, "BinlogStreamer must not be nil")
+	assert.Nil(t, verifier)
+}
@@ -1,16 +1,31 @@
 package test
 
 import (
+	"context"
+	sqlorig "database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	sql "github.com/Shopify/ghostferry/sqlwrapper"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/Shopify/ghostferry"
 	"github.com/Shopify/ghostferry/testhelpers"
 	"github.com/siddontang/go-mysql/schema"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -96,118 +111,2895 @@ func (t *IterativeVerifierTestSuite) TestVerifyOnceFails() {
 	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 42", result.Message)
 }
 
+func (t *IterativeVerifierTestSuite) TestRejectsBinaryVerificationKeyColumn() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN uuid BINARY(16)")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+
+	t.verifier.VerificationKeyColumns = map[string]string{testhelpers.TestTable1Name: "uuid"}
+	err := t.verifier.Initialize()
+	t.Require().EqualError(err, "verification key column uuid of table gftest.test_table_1 must be an unsigned integer")
+}
+
+func (t *IterativeVerifierTestSuite) TestRejectsSignedVerificationKeyColumn() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN external_id bigint(20)")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+
+	t.verifier.VerificationKeyColumns = map[string]string{testhelpers.TestTable1Name: "external_id"}
+	err := t.verifier.Initialize()
+	t.Require().EqualError(err, "verification key column external_id of table gftest.test_table_1 must be an unsigned integer")
+}
+
+func (t *IterativeVerifierTestSuite) TestRejectsUnknownVerificationKeyColumn() {
+	t.verifier.VerificationKeyColumns = map[string]string{testhelpers.TestTable1Name: "external_id"}
+	err := t.verifier.Initialize()
+	t.Require().EqualError(err, "verification key column external_id of table gftest.test_table_1 does not exist")
+}
+
+func (t *IterativeVerifierTestSuite) TestRejectsUnsupportedPaginationKeyColumn() {
+	table := *t.table
+	table.PaginationKeyColumn = &schema.TableColumn{Name: "created_at", Type: schema.TYPE_DATETIME, RawType: "datetime"}
+	t.verifier.Tables = []*ghostferry.TableSchema{&table}
+
+	err := t.verifier.Initialize()
+	t.Require().EqualError(err, "paginationKey column created_at of table gftest.test_table_1 is of unsupported type datetime, it must be an integer")
+
+	table.PaginationKeyColumn = nil
+	err = t.verifier.Initialize()
+	t.Require().EqualError(err, "table gftest.test_table_1 has no paginationKey column")
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithVerificationKeyColumnFailsOnRemappedPaginationKey() {
+	t.addExternalIdColumn()
+	t.verifier.VerificationKeyColumns = map[string]string{testhelpers.TestTable1Name: "external_id"}
+
+	_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"foo\", 7)")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 VALUES (43, \"foo\", 7)")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 7", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceFailsOnGeometrySridMismatch() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN location GEOMETRY")
+		t.Require().Nil(err)
+
+		_, err = db.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"foo\", ST_GeomFromText('POINT(1 1)', 0))")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+
+	_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.test_table_1 VALUES (43, \"foo\", ST_GeomFromText('POINT(1 1)', 0))")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 VALUES (43, \"foo\", ST_GeomFromText('POINT(1 1)', 4326))")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithVerificationKeyColumnPasses() {
+	t.addExternalIdColumn()
+	t.verifier.VerificationKeyColumns = map[string]string{testhelpers.TestTable1Name: "external_id"}
+
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"foo\", 7)")
+		t.Require().Nil(err)
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceFailsOnNullVerificationKey() {
+	t.addExternalIdColumn()
+	t.verifier.VerificationKeyColumns = map[string]string{testhelpers.TestTable1Name: "external_id"}
+
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"foo\", NULL)")
+		t.Require().Nil(err)
+	}
+
+	_, err := t.verifier.VerifyOnce()
+	t.Require().NotNil(err)
+	t.Require().Equal("NULL primary key encountered in table gftest.test_table_1", err.Error())
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOncePassesWithDifferentTargetColumnOrder() {
+	_, err := t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 MODIFY data TEXT FIRST")
+	t.Require().Nil(err)
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 (id, data) VALUES (42, \"foo\")")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestWarnsIfColumnOrderOfResolvedTargetDiffers() {
+	_, err := t.Ferry.TargetDB.Exec("CREATE TABLE gftest.test_table_1_odd (data TEXT, id bigint(20) not null, primary key(id))")
+	t.Require().Nil(err)
+
+	t.verifier.TargetResolvers = map[string]ghostferry.TargetResolver{
+		testhelpers.TestTable1Name: func(paginationKey uint64) (string, string) {
+			if paginationKey%2 == 0 {
+				return testhelpers.TestSchemaName, testhelpers.TestTable1Name
+			}
+			return testhelpers.TestSchemaName, "test_table_1_odd"
+		},
+	}
+
+	t.InsertRowInDb(43, "bar", t.Ferry.SourceDB)
+	t.InsertRowInDb(45, "bar", t.Ferry.SourceDB)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1_odd (id, data) VALUES (43, \"bar\"), (45, \"bar\")")
+	t.Require().Nil(err)
+
+	hook := &entriesHook{}
+	logger := logrus.StandardLogger()
+	oldHooks := logger.Hooks
+	logger.Hooks = make(logrus.LevelHooks)
+	logger.Hooks.Add(hook)
+	defer func() { logger.Hooks = oldHooks }()
+
+	t.verifier.CursorConfig.BatchSize = 1
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	// The resolved target is checked once, rather than the default target
+	// the rows are not resolved to.
+	var targetTables []interface{}
+	for _, entry := range hook.entries {
+		if entry.Message == "column order differs between source and target, columns are fingerprinted by name in source order" {
+			targetTables = append(targetTables, entry.Data["target_table"])
+		}
+	}
+	t.Require().Equal([]interface{}{"gftest.test_table_1_odd"}, targetTables)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceFailsOnPaginationKeySignednessMismatch() {
+	t.SetColumnType(testhelpers.TestSchemaName, testhelpers.TestTable1Name, "id", "bigint(20) unsigned not null auto_increment", t.Ferry.SourceDB)
+	t.reloadTables()
+
+	// Both rows have the same binary representation.
+	_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.test_table_1 VALUES (18446744073709551615, 'foo')")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 VALUES (-1, 'foo')")
+	t.Require().Nil(err)
+
+	_, err = t.verifier.VerifyOnce()
+	t.Require().NotNil(err)
+	t.Require().Equal("column id of table gftest.test_table_1 is unsigned on the source but signed on the target, values may be interpreted differently", err.Error())
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithTargetResolver() {
+	_, err := t.Ferry.TargetDB.Exec("CREATE TABLE gftest.test_table_1_odd LIKE gftest.test_table_1")
+	t.Require().Nil(err)
+
+	t.verifier.TargetResolvers = map[string]ghostferry.TargetResolver{
+		testhelpers.TestTable1Name: func(paginationKey uint64) (string, string) {
+			if paginationKey%2 == 0 {
+				return testhelpers.TestSchemaName, testhelpers.TestTable1Name
+			}
+			return testhelpers.TestSchemaName, "test_table_1_odd"
+		},
+	}
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1_odd VALUES (43, \"bar\")")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	_, err = t.Ferry.TargetDB.Exec("UPDATE gftest.test_table_1_odd SET data = \"baz\" WHERE id = 43")
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithVerifyTailRows() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		t.InsertRowInDb(43, "foo", db)
+		t.InsertRowInDb(44, "foo", db)
+	}
+
+	t.verifier.VerifyTailRows = 2
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	t.verifier.VerifyTailRows = 3
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithVerifyTailRowsOnlyCountsRowsMatchingVerifyWhere() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		for id := 43; id < 47; id++ {
+			t.InsertRowInDb(id, "foo", db)
+		}
+	}
+
+	t.verifier.VerifyWhere = map[string]string{testhelpers.TestTable1Name: "id < 45"}
+	t.verifier.VerifyTailRows = 3
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 42", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyDescending() {
+	for id := 40; id < 50; id++ {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "foo", t.Ferry.TargetDB)
+	}
+	t.UpdateRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.UpdateRowInDb(47, "bar", t.Ferry.TargetDB)
+
+	t.verifier.CursorConfig.BatchSize = 3
+	t.verifier.VerifyDescending = true
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 47", result.Message)
+
+	err = t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	mismatchedPaginationKeys := make([]uint64, 0, len(result.Mismatches))
+	for _, mismatch := range result.Mismatches {
+		mismatchedPaginationKeys = append(mismatchedPaginationKeys, mismatch.PaginationKey)
+	}
+	t.Require().ElementsMatch([]uint64{42, 47}, mismatchedPaginationKeys)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithReplicationLagTolerance() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN updated_at DATETIME(6)")
+		t.Require().Nil(err)
+		_, err = db.Exec("INSERT INTO gftest.test_table_1 (id, data, updated_at) VALUES (42, 'foo', '2020-01-01'), (43, 'foo', NOW(6))")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+
+	t.verifier.ReplicationLagTolerance = 100 * time.Millisecond
+	t.verifier.ModificationTimestampColumns = map[string]string{testhelpers.TestTable1Name: "updated_at"}
+	t.verifier.VerifyWhere = map[string]string{testhelpers.TestTable1Name: "id < 100"}
+
+	// The target catches up as soon as the recently modified rows are
+	// queried, before the verifier waits for it.
+	var recentlyModifiedQueries []string
+	var catchUpErr error
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		if strings.Contains(query, "INTERVAL ? MICROSECOND") {
+			recentlyModifiedQueries = append(recentlyModifiedQueries, query)
+			_, catchUpErr = t.Ferry.TargetDB.Exec("UPDATE gftest.test_table_1 SET data = 'bar' WHERE id = 43")
+		}
+		return query, args
+	}
+
+	// The modification timestamp is in the future so that the row is recent
+	// however long the test takes.
+	_, err := t.Ferry.SourceDB.Exec("UPDATE gftest.test_table_1 SET data = 'bar', updated_at = NOW(6) + INTERVAL 1 DAY WHERE id = 43")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().Nil(catchUpErr)
+	t.Require().True(result.DataCorrect)
+	t.Require().Len(recentlyModifiedQueries, 1)
+	t.Require().Contains(recentlyModifiedQueries[0], "id < 100")
+
+	t.UpdateRowInDb(42, "bar", t.Ferry.SourceDB)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 42", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyWithWindowChecksums() {
+	for id := 40; id < 50; id++ {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "foo", t.Ferry.TargetDB)
+	}
+
+	t.verifier.WindowChecksumSize = 3
+	t.verifier.CursorConfig.BatchSize = 2
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	t.UpdateRowInDb(44, "bar", t.Ferry.TargetDB)
+
+	err = t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	mismatchedPaginationKeys := make([]uint64, 0, len(result.Mismatches))
+	for _, mismatch := range result.Mismatches {
+		mismatchedPaginationKeys = append(mismatchedPaginationKeys, mismatch.PaginationKey)
+	}
+	t.Require().Equal([]uint64{44}, mismatchedPaginationKeys)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithIndexHints() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	t.verifier.IndexHints = map[string]string{
+		testhelpers.TestTable1Name: "FORCE INDEX (PRIMARY)",
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	t.verifier.IndexHints[testhelpers.TestTable1Name] = "FORCE INDEX (missing_index)"
+	_, err = t.verifier.VerifyOnce()
+	t.Require().NotNil(err)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithComputedColumn() {
+	_, err := t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN data_length bigint(20)")
+	t.Require().Nil(err)
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 (id, data, data_length) VALUES (42, 'foo', 3)")
+	t.Require().Nil(err)
+
+	t.verifier.ComputedColumns = map[string]map[string]string{
+		testhelpers.TestTable1Name: map[string]string{"data_length": "LENGTH(`data`)"},
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	_, err = t.Ferry.TargetDB.Exec("UPDATE gftest.test_table_1 SET data_length = 4 WHERE id = 42")
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyPaginationKeyRange() {
+	for id := 40; id < 50; id++ {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "foo", t.Ferry.TargetDB)
+	}
+	t.UpdateRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.UpdateRowInDb(44, "bar", t.Ferry.TargetDB)
+	t.UpdateRowInDb(46, "bar", t.Ferry.TargetDB)
+
+	t.verifier.CursorConfig.BatchSize = 2
+
+	result, err := t.verifier.VerifyPaginationKeyRange(t.table, 42, 46)
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKeys: 42,44", result.Message)
+
+	result, err = t.verifier.VerifyPaginationKeyRange(t.table, 47, 50)
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyPaginationKeysFromReader() {
+	for id := 40; id < 50; id++ {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "foo", t.Ferry.TargetDB)
+	}
+	t.UpdateRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.UpdateRowInDb(44, "bar", t.Ferry.TargetDB)
+	t.UpdateRowInDb(46, "bar", t.Ferry.TargetDB)
+
+	t.verifier.CursorConfig.BatchSize = 2
+
+	result, err := t.verifier.VerifyPaginationKeysFromReader(t.table, strings.NewReader("41\n44\n\n 46 \n48\n42\n"))
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKeys: 42,44,46", result.Message)
+
+	result, err = t.verifier.VerifyPaginationKeysFromReader(t.table, strings.NewReader("41\n43\n"))
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	_, err = t.verifier.VerifyPaginationKeysFromReader(t.table, strings.NewReader("41\nfoo\n"))
+	t.Require().EqualError(err, "invalid paginationKey on line 2: strconv.ParseUint: parsing \"foo\": invalid syntax")
+}
+
 func (t *IterativeVerifierTestSuite) TestVerifyCompressedOnceFails() {
 	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, t.Ferry.SourceDB)
 	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData2, t.Ferry.TargetDB)
 
 	result, err := t.verifier.VerifyOnce()
-	t.Require().NotNil(result)
+	t.Require().NotNil(result)
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal(
+		fmt.Sprintf("verification failed on table: %s.%s for paginationKey: %s", testhelpers.TestSchemaName, testhelpers.TestCompressedTable1Name, "42"),
+		result.Message,
+	)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifiesLargestTablesFirst() {
+	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, t.Ferry.SourceDB)
+	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData2, t.Ferry.TargetDB)
+	for id := 42; id < 52; id++ {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "bar", t.Ferry.TargetDB)
+	}
+
+	for _, table := range []string{testhelpers.TestTable1Name, testhelpers.TestCompressedTable1Name} {
+		_, err := t.Ferry.SourceDB.Exec(fmt.Sprintf("ANALYZE TABLE %s.%s", testhelpers.TestSchemaName, table))
+		t.Require().Nil(err)
+	}
+
+	// The larger table is verified first regardless of its position, which
+	// the first mismatch stopping the verification shows.
+	largest := t.Ferry.Tables.Get(testhelpers.TestSchemaName, testhelpers.TestTable1Name)
+	smallest := t.Ferry.Tables.Get(testhelpers.TestSchemaName, testhelpers.TestCompressedTable1Name)
+	t.verifier.Tables = []*ghostferry.TableSchema{smallest, largest}
+	t.verifier.VerifyLargestTablesFirst = true
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.IncorrectTables)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceChecksTargetMaxPaginationKey() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(50, "garbage", t.Ferry.TargetDB)
+	t.InsertRowInDb(51, "garbage", t.Ferry.TargetDB)
+
+	t.verifier.CheckTargetMaxPaginationKey = true
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("target has unexpected rows: 2 rows of table gftest.test_table_1 on the target have paginationKeys above 43, the largest on the source, up to 51", result.Message)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.IncorrectTables)
+
+	t.verifier.MaxPaginationKeyTolerances = map[string]uint64{testhelpers.TestTable1Name: 10}
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithMergedTablePredicates() {
+	// The target table also holds the rows merged from another source table.
+	_, err := t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN origin VARCHAR(16) NOT NULL DEFAULT 'table_1'")
+	t.Require().Nil(err)
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"foo\", 'table_1'), (50, \"foo\", 'table_2')")
+	t.Require().Nil(err)
+
+	t.verifier.CheckTargetMaxPaginationKey = true
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	t.verifier.MergedTablePredicates = map[string]string{testhelpers.TestTable1Name: "origin = 'table_1'"}
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	// The whole-table checks only count the rows merged from the table.
+	t.verifier.Aggregates = map[string][]ghostferry.Aggregate{
+		testhelpers.TestTable1Name: []ghostferry.Aggregate{ghostferry.Aggregate{Function: "COUNT", Column: "*"}},
+	}
+	t.verifier.DistributionColumns = map[string][]string{testhelpers.TestTable1Name: []string{"data"}}
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	t.UpdateRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 42", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithSourceAsTarget() {
+	_, err := t.Ferry.SourceDB.Exec("CREATE DATABASE gftest_copy")
+	t.Require().Nil(err)
+	defer t.Ferry.SourceDB.Exec("DROP DATABASE IF EXISTS gftest_copy")
+
+	_, err = t.Ferry.SourceDB.Exec("CREATE TABLE gftest_copy.test_table_1 LIKE gftest.test_table_1")
+	t.Require().Nil(err)
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	_, err = t.Ferry.SourceDB.Exec("INSERT INTO gftest_copy.test_table_1 VALUES (42, \"foo\"), (43, \"bar\")")
+	t.Require().Nil(err)
+
+	// The target is another schema of the source instance, through a second
+	// connection pool of a single connection.
+	targetDB, err := t.Ferry.Config.Source.SqlDB(nil)
+	t.Require().Nil(err)
+	defer targetDB.Close()
+	targetDB.SetMaxOpenConns(1)
+
+	t.verifier.TargetDB = targetDB
+	t.verifier.DatabaseRewrites = map[string]string{testhelpers.TestSchemaName: "gftest_copy"}
+	t.verifier.Tables = []*ghostferry.TableSchema{t.table}
+	t.Require().Nil(t.verifier.Initialize())
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestRunStandaloneVerification() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	config := *t.Ferry.Config
+	config.VerifierType = ghostferry.VerifierTypeIterative
+	config.IterativeVerifierConfig = ghostferry.IterativeVerifierConfig{Concurrency: 1}
+
+	result, err := ghostferry.RunStandaloneVerification(&config)
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+
+	t.UpdateRowInDb(43, "foo", t.Ferry.TargetDB)
+
+	result, err = ghostferry.RunStandaloneVerification(&config)
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceSamplesTablesExceedingTimeBudget() {
+	for id := 1; id <= 4; id++ {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "foo", t.Ferry.TargetDB)
+	}
+	t.UpdateRowInDb(2, "bar", t.Ferry.TargetDB)
+	t.UpdateRowInDb(4, "bar", t.Ferry.TargetDB)
+
+	// The budget is exceeded by the first batch, of which every other row is
+	// verified.
+	t.verifier.TableTimeBudget = time.Nanosecond
+	t.verifier.TableTimeBudgetSampleRate = 0.5
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.PartiallyVerifiedTables)
+	t.Require().Equal(uint64(2), t.verifier.Progress().RowsVerified)
+
+	t.verifier.TableTimeBudget = 0
+	t.verifier.Reset()
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Nil(result.PartiallyVerifiedTables)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceIteratesSnapshotOfTables() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("CREATE TABLE gftest.test_table_2 LIKE gftest.test_table_1")
+		t.Require().Nil(err)
+
+		_, err = db.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"foo\")")
+		t.Require().Nil(err)
+		_, err = db.Exec("INSERT INTO gftest.test_table_2 VALUES (42, \"foo\")")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+	t.Require().Equal(2, len(t.verifier.Tables))
+
+	// Only found by the check of the largest paginationKeys following the
+	// pass over the tables.
+	_, err := t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_2 VALUES (43, \"foo\")")
+	t.Require().Nil(err)
+	t.verifier.CheckTargetMaxPaginationKey = true
+
+	// The Tables are replaced while the first table is verified, as a reload
+	// of the schemas could. Run with go test -race, which reports replacing
+	// them other than through SetTables.
+	var clearTables sync.Once
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		clearTables.Do(func() {
+			t.verifier.SetTables(nil)
+		})
+		return query, args
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"gftest.test_table_2"}, result.IncorrectTables)
+	t.Require().Equal(uint64(2), t.verifier.Progress().RowsVerified)
+	t.Require().Equal(2, len(t.verifier.Results()))
+}
+
+func (t *IterativeVerifierTestSuite) TestResultsByTable() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("CREATE TABLE gftest.test_table_2 LIKE gftest.test_table_1")
+		t.Require().Nil(err)
+
+		_, err = db.Exec("INSERT INTO gftest.test_table_2 VALUES (42, \"foo\")")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	t.Require().Nil(t.verifier.VerifyBeforeCutover())
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	results := t.verifier.Results()
+	t.Require().Equal(2, len(results))
+
+	table1 := results[ghostferry.TableIdentifier{SchemaName: "gftest", TableName: "test_table_1"}]
+	t.Require().False(table1.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKeys: 43", table1.Message)
+	t.Require().Equal(1, len(table1.Mismatches))
+	t.Require().Equal(uint64(43), table1.Mismatches[0].PaginationKey)
+	t.Require().True(table1.RowsVerified >= 2)
+	t.Require().Nil(table1.Err)
+
+	table2 := results[ghostferry.TableIdentifier{SchemaName: "gftest", TableName: "test_table_2"}]
+	t.Require().True(table2.DataCorrect)
+	t.Require().Equal(uint64(1), table2.RowsVerified)
+	t.Require().Nil(table2.Err)
+
+	t.verifier.Reset()
+	t.Require().Empty(t.verifier.Results())
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithWidenedIntegerColumn() {
+	_, err := t.Ferry.SourceDB.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN count int(5) unsigned zerofill")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN count bigint(20) unsigned")
+	t.Require().Nil(err)
+	t.reloadTables()
+
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"foo\", 7)")
+		t.Require().Nil(err)
+	}
+
+	// The zero-filled value is hashed as formatted unless the column is
+	// declared widened.
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	t.verifier.WidenedColumns = map[string]map[string]struct{}{testhelpers.TestTable1Name: {"count": struct{}{}}}
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithWidenedVarcharColumn() {
+	_, err := t.Ferry.SourceDB.Exec("ALTER TABLE gftest.test_table_1 MODIFY data varchar(50) CHARACTER SET latin1")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 MODIFY data varchar(255) CHARACTER SET utf8mb4")
+	t.Require().Nil(err)
+	t.reloadTables()
+	t.verifier.WidenedColumns = map[string]map[string]struct{}{testhelpers.TestTable1Name: {"data": struct{}{}}}
+
+	t.InsertRowInDb(42, "caf\u00e9", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "caf\u00e9", t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	t.UpdateRowInDb(42, "cafe", t.Ferry.TargetDB)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithShards() {
+	for id := 1; id <= 4; id++ {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "foo", t.Ferry.TargetDB)
+	}
+	t.UpdateRowInDb(3, "bar", t.Ferry.TargetDB)
+
+	t.verifier.ShardCount = 2
+	t.verifier.ShardIndex = 0
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal(uint64(2), t.verifier.Progress().RowsVerified)
+
+	t.verifier.Reset()
+	t.verifier.ShardIndex = 1
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 3", result.Message)
+
+	t.verifier.ShardIndex = 2
+	t.Require().EqualError(t.verifier.Initialize(), "ShardIndex must be between 0 and ShardCount 2, not 2")
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceFailsWhenTargetDoesNotExecuteSourceGtid() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	// The target is not a replica of the source, so it never executes the
+	// transactions of the source.
+	t.verifier.WaitForSourceGtid = true
+	t.verifier.GtidWaitTimeout = 100 * time.Millisecond
+
+	_, err := t.verifier.VerifyOnce()
+	t.Require().NotNil(err)
+	t.Require().Contains(err.Error(), "of the source within the GtidWaitTimeout of 100ms")
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWaitsForTheTargetToExecuteSourceGtid() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.executeSourceGtidsOnTarget()
+
+	var queries []string
+	var queriesMutex sync.Mutex
+	t.verifier.IgnoredTables = []string{testhelpers.TestCompressedTable1Name}
+	t.verifier.WaitForSourceGtid = true
+	t.verifier.GtidWaitTimeout = 5 * time.Second
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		queriesMutex.Lock()
+		defer queriesMutex.Unlock()
+		queries = append(queries, query)
+		return query, args
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	// The GTID set is captured once the source is fingerprinted, and the
+	// target is only fingerprinted once it executed the GTID set.
+	queryIndex := func(prefix string, after int) int {
+		for idx := after + 1; idx < len(queries); idx++ {
+			if strings.HasPrefix(queries[idx], prefix) {
+				return idx
+			}
+		}
+		return -1
+	}
+
+	sourceFingerprints := queryIndex("SELECT `id`, MD5(", -1)
+	gtidExecuted := queryIndex("SELECT @@GLOBAL.gtid_executed", sourceFingerprints)
+	gtidWait := queryIndex("SELECT WAIT_FOR_EXECUTED_GTID_SET(", gtidExecuted)
+	targetFingerprints := queryIndex("SELECT `id`, MD5(", gtidWait)
+	t.Require().NotEqual(-1, sourceFingerprints)
+	t.Require().NotEqual(-1, gtidExecuted)
+	t.Require().NotEqual(-1, gtidWait)
+	t.Require().NotEqual(-1, targetFingerprints)
+}
+
+// Executes an empty transaction on the target for each GTID executed by the
+// source but not by the target, as if the target replicated the source.
+func (t *IterativeVerifierTestSuite) executeSourceGtidsOnTarget() {
+	var sourceGtidSet, missingGtidSet string
+	t.Require().Nil(t.Ferry.SourceDB.QueryRow("SELECT @@GLOBAL.gtid_executed").Scan(&sourceGtidSet))
+	t.Require().Nil(t.Ferry.TargetDB.QueryRow("SELECT GTID_SUBTRACT(?, @@GLOBAL.gtid_executed)", sourceGtidSet).Scan(&missingGtidSet))
+
+	conn, err := t.Ferry.TargetDB.Conn(context.Background())
+	t.Require().Nil(err)
+	defer conn.Close()
+
+	exec := func(query string) {
+		_, err := conn.ExecContext(context.Background(), query)
+		t.Require().Nil(err)
+	}
+
+	for _, uuidSet := range strings.Split(strings.Replace(missingGtidSet, "\n", "", -1), ",") {
+		if uuidSet == "" {
+			continue
+		}
+
+		parts := strings.Split(uuidSet, ":")
+		for _, interval := range parts[1:] {
+			bounds := strings.Split(interval, "-")
+			first, err := strconv.ParseUint(bounds[0], 10, 64)
+			t.Require().Nil(err)
+			last, err := strconv.ParseUint(bounds[len(bounds)-1], 10, 64)
+			t.Require().Nil(err)
+
+			for transaction := first; transaction <= last; transaction++ {
+				exec(fmt.Sprintf("SET GTID_NEXT = '%s:%d'", parts[0], transaction))
+				exec("BEGIN")
+				exec("COMMIT")
+			}
+		}
+	}
+
+	exec("SET GTID_NEXT = 'AUTOMATIC'")
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceFailsOnBatchTimeout() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	t.verifier.BatchTimeout = time.Nanosecond
+
+	_, err := t.verifier.VerifyOnce()
+	t.Require().EqualError(err, "batch of table gftest.test_table_1 did not complete within the BatchTimeout of 1ns")
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceIgnoresOldVersionMismatches() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN schema_version INT NOT NULL DEFAULT 1")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+
+	_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"foo\", 1), (43, \"foo\", 2)")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"bar\", 1), (43, \"foo\", 2)")
+	t.Require().Nil(err)
+
+	t.verifier.CurrentVersionPredicates = map[string]string{testhelpers.TestTable1Name: "schema_version = 2"}
+
+	var versionQueries int32
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		if strings.Contains(query, "NOT (schema_version = 2)") {
+			atomic.AddInt32(&versionQueries, 1)
+		}
+		return query, args
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal(uint64(1), t.verifier.Progress().OldVersionMismatches)
+	t.Require().NotZero(atomic.LoadInt32(&versionQueries))
+
+	t.UpdateRowInDb(43, "bar", t.Ferry.TargetDB)
+	t.verifier.Reset()
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+	t.Require().Equal(uint64(1), t.verifier.Progress().OldVersionMismatches)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithCrc32() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	t.verifier.FingerprintHashFunction = ghostferry.FingerprintHashCRC32
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+
+	t.verifier.FingerprintHashFunction = "SHA1"
+	t.Require().EqualError(t.verifier.Initialize(), "unknown FingerprintHashFunction SHA1")
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithRowFingerprintAlias() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	var fingerprintQueries int
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		if strings.Contains(query, "AS fingerprint FROM") {
+			fingerprintQueries++
+		}
+		return query, args
+	}
+	t.verifier.RowFingerprintAlias = "fingerprint"
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+	t.Require().NotZero(fingerprintQueries)
+
+	t.verifier.RowFingerprintAlias = "DATA"
+	t.Require().EqualError(t.verifier.Initialize(), "RowFingerprintAlias DATA collides with column data of table gftest.test_table_1")
+
+	t.verifier.RowFingerprintAlias = "fingerprint FROM"
+	t.Require().EqualError(t.verifier.Initialize(), "RowFingerprintAlias fingerprint FROM must be an identifier of letters, digits and underscores")
+
+	// A table with a column named as the default alias needs another alias.
+	_, err = t.Ferry.SourceDB.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN row_fingerprint VARCHAR(32)")
+	t.Require().Nil(err)
+	t.reloadTables()
+
+	t.verifier.RowFingerprintAlias = ""
+	t.Require().EqualError(t.verifier.Initialize(), "RowFingerprintAlias row_fingerprint collides with column row_fingerprint of table gftest.test_table_1")
+
+	t.verifier.RowFingerprintAlias = "fingerprint"
+	t.Require().Nil(t.verifier.Initialize())
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceIgnoresPaddingOfCharMigratedToVarchar() {
+	_, err := t.Ferry.SourceDB.Exec("ALTER TABLE gftest.test_table_1 MODIFY data CHAR(10)")
+	t.Require().Nil(err)
+	t.reloadTables()
+
+	t.InsertRowInDb(42, "abc", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "abc  ", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "abc", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "abcd", t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithColumnTransformations() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "FOO", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	t.verifier.ColumnTransformations = map[string]map[string]string{
+		testhelpers.TestTable1Name: map[string]string{"data": "UPPER(`data`)"},
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOncePass() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().NotNil(result)
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal("", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyCompressedOncePass() {
+	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, t.Ferry.SourceDB)
+	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().NotNil(result)
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal("", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyDifferentCompressedSameDecompressedDataOncePass() {
+	t.Require().NotEqual(testhelpers.TestCompressedData3, testhelpers.TestCompressedData4)
+
+	t.InsertCompressedRowInDb(43, testhelpers.TestCompressedData3, t.Ferry.SourceDB)
+	t.InsertCompressedRowInDb(43, testhelpers.TestCompressedData4, t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().NotNil(result)
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal("", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestFailsOnUnexpectedlyEmptyTable() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	_, err := t.Ferry.SourceDB.Exec("ANALYZE TABLE gftest.test_table_1")
+	t.Require().Nil(err)
+
+	t.verifier.FailOnUnexpectedlyEmptyTables = true
+	t.verifier.CursorConfig.BuildSelect = func(columns []string, table *ghostferry.TableSchema, lastPaginationKey, batchSize uint64) (sq.SelectBuilder, error) {
+		return ghostferry.DefaultBuildSelect(columns, table, lastPaginationKey, batchSize).Where("1 = 0"), nil
+	}
+
+	_, err = t.verifier.VerifyOnce()
+	t.Require().NotNil(err)
+	t.Require().Regexp("no rows verified on table gftest.test_table_1", err.Error())
+}
+
+func (t *IterativeVerifierTestSuite) TestBeforeCutoverFailuresFailAgainDuringCutover() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKeys: 42", result.Message)
+	t.Require().Equal(
+		[]ghostferry.VerificationMismatch{ghostferry.NewVerificationMismatch(ghostferry.TableIdentifier{SchemaName: "gftest", TableName: "test_table_1"}, 42)},
+		result.Mismatches,
+	)
+}
+
+func (t *IterativeVerifierTestSuite) TestReverifyFailurePolicy() {
+	for _, id := range []int{42, 43} {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "bar", t.Ferry.TargetDB)
+	}
+
+	t.verifier.CursorConfig.BatchSize = 1
+	t.verifier.ReverifyFailurePolicy = ghostferry.ReverifyAbortOnError
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal(
+		"verification failed on table: gftest.test_table_1 for paginationKeys: 42; verification failed on table: gftest.test_table_1 for paginationKeys: 43",
+		result.Message,
+	)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.IncorrectTables)
+	t.Require().Equal(2, len(result.Mismatches))
+	t.Require().Equal(uint64(42), result.Mismatches[0].PaginationKey)
+	t.Require().Equal(uint64(43), result.Mismatches[1].PaginationKey)
+}
+
+func (t *IterativeVerifierTestSuite) TestResetAllowsVerifyingAgain() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	err = t.verifier.StartInBackground()
+	t.Require().Nil(err)
+	t.verifier.Wait()
+
+	result, err := t.verifier.Result()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	err = t.verifier.StartInBackground()
+	t.Require().NotNil(err)
+
+	t.UpdateRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.verifier.Reset()
+
+	err = t.verifier.StartInBackground()
+	t.Require().EqualError(err, "VerifyBeforeCutover() must be called before this")
+
+	err = t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	err = t.verifier.StartInBackground()
+	t.Require().Nil(err)
+	t.verifier.Wait()
+
+	result, err = t.verifier.Result()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestBeforeCutoverCompressionFailuresFailAgainDuringCutover() {
+	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, t.Ferry.SourceDB)
+	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData2, t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal(fmt.Sprintf("verification failed on table: %s.%s for paginationKeys: %s", "gftest", testhelpers.TestCompressedTable1Name, "42"), result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestBeforeCutoverDifferentCompressedSameDecompressedDataPassDuringCutover() {
+	t.Require().NotEqual(testhelpers.TestCompressedData3, testhelpers.TestCompressedData4)
+
+	t.InsertCompressedRowInDb(43, testhelpers.TestCompressedData3, t.Ferry.SourceDB)
+	t.InsertCompressedRowInDb(43, testhelpers.TestCompressedData4, t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal("", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestErrorsIfMaxDowntimeIsSurpassed() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	t.verifier.MaxExpectedDowntime = 1 * time.Nanosecond
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Regexp("cutover stage verification will not complete within max downtime duration \\(took .*\\)", err.Error())
+}
+
+func (t *IterativeVerifierTestSuite) TestErrorsIfDeadlineHasPassed() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	t.verifier.Deadline = time.Now().Add(-1 * time.Second)
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Equal(ghostferry.ErrDeadlineExceeded, err)
+}
+
+func (t *IterativeVerifierTestSuite) TestReportsCutoverMismatchesThroughTheFerry() {
+	t.Ferry.Verifier = t.verifier
+	t.Require().Nil(t.Ferry.Progress().VerificationMismatches)
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	report := t.Ferry.Progress().VerificationMismatches
+	t.Require().NotNil(report)
+	t.Require().Equal(result.Message, report.Message)
+	t.Require().Equal(1, len(report.Tables["gftest.test_table_1"]))
+	t.Require().Equal(uint64(42), report.Tables["gftest.test_table_1"][42].PaginationKey)
+
+	stateJSON, err := t.Ferry.SerializeStateToJSON()
+	t.Require().Nil(err)
+
+	var state ghostferry.SerializableState
+	t.Require().Nil(json.Unmarshal([]byte(stateJSON), &state))
+	t.Require().NotNil(state.VerificationMismatches)
+	t.Require().Equal(uint64(42), state.VerificationMismatches.Tables["gftest.test_table_1"][42].PaginationKey)
+
+	t.verifier.Reset()
+	t.Require().Nil(t.Ferry.Progress().VerificationMismatches)
+}
+
+func (t *IterativeVerifierTestSuite) TestReportsRowsMissingOnBothSides() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	// The mismatched row is reverified during cutover, by which point it was
+	// deleted on both sides.
+	_, err = t.Ferry.SourceDB.Exec("DELETE FROM gftest.test_table_1 WHERE id = 42")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("DELETE FROM gftest.test_table_1 WHERE id = 42")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal(uint64(1), t.verifier.Progress().RowsMissingOnBothSides)
+}
+
+func (t *IterativeVerifierTestSuite) TestReportsSourceInconsistentRows() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	// The row changes on the source before it is read the second time,
+	// after the first reads of the source and the target.
+	var queryCount int32
+	t.verifier.SourceSelfConsistencyCheck = true
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		if atomic.AddInt32(&queryCount, 1) == 3 {
+			t.UpdateRowInDb(42, "bar", t.Ferry.SourceDB)
+		}
+		return query, args
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal(int32(3), atomic.LoadInt32(&queryCount))
+	t.Require().Equal(uint64(1), t.verifier.Progress().SourceInconsistentRows)
+}
+
+func (t *IterativeVerifierTestSuite) TestPrunesRowsDeletedOnBothSides() {
+	t.verifier.PruneDeletedRows = true
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	_, err = t.Ferry.SourceDB.Exec("DELETE FROM gftest.test_table_1 WHERE id = 42")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("DELETE FROM gftest.test_table_1 WHERE id = 42")
+	t.Require().Nil(err)
+
+	// The deleted row is pruned, while the remaining row still mismatches.
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal(1, len(result.Mismatches))
+	t.Require().Equal(uint64(43), result.Mismatches[0].PaginationKey)
+	t.Require().Equal(uint64(1), t.verifier.Progress().RowsMissingOnBothSides)
+}
+
+func (t *IterativeVerifierTestSuite) TestNotifiesStateChanges() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	transitions := make([][2]ghostferry.VerifierState, 0)
+	t.verifier.OnStateChange = func(from, to ghostferry.VerifierState) {
+		transitions = append(transitions, [2]ghostferry.VerifierState{from, to})
+	}
+
+	t.Require().Equal(ghostferry.VerifierStateIdle, t.verifier.State())
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+	t.Require().Equal(ghostferry.VerifierStateBeforeCutover, t.verifier.State())
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal(ghostferry.VerifierStateDone, t.verifier.State())
+
+	t.verifier.Reset()
+	t.Require().Equal(ghostferry.VerifierStateIdle, t.verifier.State())
+
+	t.Require().Equal([][2]ghostferry.VerifierState{
+		{ghostferry.VerifierStateIdle, ghostferry.VerifierStateBeforeCutover},
+		{ghostferry.VerifierStateBeforeCutover, ghostferry.VerifierStateCutover},
+		{ghostferry.VerifierStateCutover, ghostferry.VerifierStateDone},
+		{ghostferry.VerifierStateDone, ghostferry.VerifierStateIdle},
+	}, transitions)
+}
+
+func (t *IterativeVerifierTestSuite) TestStateChangeCallbackCanReadState() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	states := make([]ghostferry.VerifierState, 0)
+	t.verifier.OnStateChange = func(from, to ghostferry.VerifierState) {
+		states = append(states, t.verifier.State())
+	}
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	t.Require().Equal([]ghostferry.VerifierState{
+		ghostferry.VerifierStateBeforeCutover,
+		ghostferry.VerifierStateCutover,
+		ghostferry.VerifierStateDone,
+	}, states)
+}
+
+func (t *IterativeVerifierTestSuite) TestFailsStateOnIncorrectData() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal(ghostferry.VerifierStateFailed, t.verifier.State())
+}
+
+func (t *IterativeVerifierTestSuite) TestReportsHealth() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	health := t.verifier.Health(time.Hour)
+	t.Require().True(health.Healthy)
+	t.Require().Equal(ghostferry.VerificationPhaseNotStarted, health.Phase)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	health = t.verifier.Health(time.Hour)
+	t.Require().True(health.Healthy)
+	t.Require().False(health.Stalled)
+	t.Require().Equal(uint64(1), health.RowsToReverify)
+	t.Require().True(health.RowsVerified > 0)
+	t.Require().False(health.LastProgressTime.IsZero())
+
+	t.verifier.Deadline = time.Now().Add(-1 * time.Second)
+	_, err = t.verifier.VerifyDuringCutover()
+	t.Require().Equal(ghostferry.ErrDeadlineExceeded, err)
+
+	recorder := httptest.NewRecorder()
+	t.verifier.HealthHandler(time.Hour).ServeHTTP(recorder, httptest.NewRequest("GET", "/health", nil))
+	t.Require().Equal(http.StatusServiceUnavailable, recorder.Code)
+
+	t.Require().Nil(json.Unmarshal(recorder.Body.Bytes(), &health))
+	t.Require().False(health.Healthy)
+	t.Require().Equal(ghostferry.VerifierStateFailed, health.State)
+	t.Require().Equal(ghostferry.ErrDeadlineExceeded.Error(), health.LastError)
+}
+
+func (t *IterativeVerifierTestSuite) TestErrorsIfDeadlinePassesBeforeCutoverVerification() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	t.verifier.Deadline = time.Now().Add(-1 * time.Second)
+	_, err = t.verifier.VerifyDuringCutover()
+	t.Require().Equal(ghostferry.ErrDeadlineExceeded, err)
+}
+
+func (t *IterativeVerifierTestSuite) TestErrorsIfBinlogStreamerStoppedBeforeCutover() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	_, err := t.Ferry.BinlogStreamer.ConnectBinlogStreamerToMysql()
+	t.Require().Nil(err)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t.Ferry.BinlogStreamer.Run()
+	}()
+
+	t.Ferry.BinlogStreamer.FlushAndStop()
+	wg.Wait()
+
+	err = t.verifier.VerifyBeforeCutover()
+	t.Require().Equal(ghostferry.ErrBinlogStreamerStopped, err)
+}
+
+func (t *IterativeVerifierTestSuite) TestErrorsIfTargetIsUnhealthy() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	// Nothing listens on port 1 of the target host.
+	unreachableConfig := *t.Ferry.Config.Target
+	unreachableConfig.Port = 1
+	unreachableDB, err := unreachableConfig.SqlDB(nil)
+	t.Require().Nil(err)
+	defer unreachableDB.Close()
+
+	t.verifier.TargetDB = unreachableDB
+	t.verifier.TargetCircuitBreaker = ghostferry.NewCircuitBreaker(2, 0.5)
+	_, err = t.verifier.VerifyOnce()
+	t.Require().Equal(ghostferry.ErrTargetUnhealthy, err)
+}
+
+func (t *IterativeVerifierTestSuite) TestSQLErrorsDoNotMakeTheTargetUnhealthy() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	_, err := t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 DROP COLUMN data")
+	t.Require().Nil(err)
+
+	t.verifier.TargetCircuitBreaker = ghostferry.NewCircuitBreaker(2, 0.5)
+	_, err = t.verifier.VerifyOnce()
+	t.Require().NotNil(err)
+	t.Require().NotEqual(ghostferry.ErrTargetUnhealthy, err)
+	t.Require().Nil(t.verifier.TargetCircuitBreaker.Allow())
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyContinuouslyReverifiesTheRowsChangedBeforeEachPass() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.TargetDB)
+
+	// The compressed table is no longer in the table schema cache of the
+	// verifier, as after a reload of the schemas, but its changed rows are
+	// still streamed.
+	t.Ferry.BinlogStreamer.TableSchema = t.verifier.TableSchemaCache
+	verifierTables := ghostferry.TableSchemaCache{}
+	for name, table := range t.verifier.TableSchemaCache {
+		if table.Name != testhelpers.TestCompressedTable1Name {
+			verifierTables[name] = table
+		}
+	}
+	t.verifier.TableSchemaCache = verifierTables
+
+	_, err := t.Ferry.BinlogStreamer.ConnectBinlogStreamerToMysql()
+	t.Require().Nil(err)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t.Ferry.BinlogStreamer.Run()
+	}()
+	defer func() {
+		t.Ferry.BinlogStreamer.FlushAndStop()
+		wg.Wait()
+	}()
+
+	t.Require().Nil(t.verifier.VerifyBeforeCutover())
+
+	mismatches := make(chan ghostferry.VerificationResult, 10)
+	verifyErr := make(chan error, 1)
+	stop := make(chan struct{})
+	go func() {
+		verifyErr <- t.verifier.VerifyContinuously(10*time.Millisecond, stop, func(result ghostferry.VerificationResult) {
+			mismatches <- result
+		})
+	}()
+
+	// Each row is only changed on the source, and reported by the pass
+	// following its change.
+	for _, id := range []int{42, 43} {
+		_, err = t.Ferry.SourceDB.Exec(fmt.Sprintf("INSERT INTO %s.%s (data) VALUES ('baz')", testhelpers.TestSchemaName, testhelpers.TestCompressedTable1Name))
+		t.Require().Nil(err)
+		t.UpdateRowInDb(id, "bar", t.Ferry.SourceDB)
+
+		select {
+		case result := <-mismatches:
+			t.Require().False(result.DataCorrect)
+			t.Require().Len(result.Mismatches, 1)
+			t.Require().Equal(uint64(id), result.Mismatches[0].PaginationKey)
+		case err := <-verifyErr:
+			t.FailNow(fmt.Sprintf("continuous verification stopped: %v", err))
+		case <-time.After(5 * time.Second):
+			t.FailNow(fmt.Sprintf("row %d was not reported", id))
+		}
+	}
+
+	close(stop)
+	t.Require().Nil(<-verifyErr)
+	t.Require().Empty(mismatches)
+}
+
+func (t *IterativeVerifierTestSuite) TestReverifiesTheKeysOfTheReverifyKeyExtractor() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.TargetDB)
+
+	// A change of a row makes the extractor reverify the next row instead.
+	var extractedPaginationKeys []uint64
+	t.verifier.ReverifyKeyExtractor = func(ev ghostferry.DMLEvent) ([]uint64, error) {
+		paginationKey, err := ev.PaginationKey()
+		if err != nil {
+			return nil, err
+		}
+
+		extractedPaginationKeys = append(extractedPaginationKeys, paginationKey)
+		return []uint64{paginationKey + 1}, nil
+	}
+
+	t.Ferry.BinlogStreamer.TableSchema = t.verifier.TableSchemaCache
+	_, err := t.Ferry.BinlogStreamer.ConnectBinlogStreamerToMysql()
+	t.Require().Nil(err)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t.Ferry.BinlogStreamer.Run()
+	}()
+
+	t.Require().Nil(t.verifier.VerifyBeforeCutover())
+
+	// Only the target row 43 differs, which is not streamed, while the row
+	// 42 is changed on both sides.
+	t.UpdateRowInDb(43, "bar", t.Ferry.TargetDB)
+	t.UpdateRowInDb(42, "baz", t.Ferry.SourceDB)
+	t.UpdateRowInDb(42, "baz", t.Ferry.TargetDB)
+
+	t.Ferry.BinlogStreamer.FlushAndStop()
+	wg.Wait()
+	t.Require().Equal([]uint64{42}, extractedPaginationKeys)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestEstimateCutoverDuration() {
+	_, err := t.verifier.EstimateCutoverDuration()
+	t.Require().NotNil(err)
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	err = t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	// The mismatched row remains in the store to be verified during cutover.
+	estimate, err := t.verifier.EstimateCutoverDuration()
+	t.Require().Nil(err)
+	t.Require().True(estimate > 0)
+}
+
+func (t *IterativeVerifierTestSuite) TestWritesProgressSnapshots() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	snapshots := make([]ghostferry.IterativeVerifierProgress, 0)
+	t.verifier.ProgressSnapshotInterval = time.Millisecond
+	t.verifier.ProgressSnapshotWriter = func(progress ghostferry.IterativeVerifierProgress) error {
+		snapshots = append(snapshots, progress)
+		return nil
+	}
+
+	t.Require().Equal(ghostferry.VerificationPhaseNotStarted, t.verifier.Progress().Phase)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	progress := t.verifier.Progress()
+	t.Require().Equal(ghostferry.VerificationPhaseWaitingForCutover, progress.Phase)
+	t.Require().Equal(uint64(1), progress.RowsToReverify)
+
+	_, err = t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+
+	t.Require().True(len(snapshots) > 0)
+	last := snapshots[len(snapshots)-1]
+	t.Require().Equal(ghostferry.VerificationPhaseDone, last.Phase)
+	t.Require().Equal(len(t.verifier.Tables), last.CompletedTables)
+	t.Require().Equal(len(t.verifier.Tables), last.TotalTables)
+	t.Require().True(last.MismatchesFound > 0)
+	t.Require().True(last.RowsVerified > 0)
+}
+
+func (t *IterativeVerifierTestSuite) TestProgressReportsQueryLatencies() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	progress := t.verifier.Progress()
+	t.Require().Equal(time.Duration(0), progress.AverageSourceQueryLatency)
+	t.Require().Equal(time.Duration(0), progress.AverageTargetQueryLatency)
+	t.Require().Equal(float64(0), progress.TargetToSourceLatencyRatio)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	progress = t.verifier.Progress()
+	t.Require().True(progress.AverageSourceQueryLatency > 0)
+	t.Require().True(progress.AverageTargetQueryLatency > 0)
+	t.Require().InDelta(
+		float64(progress.AverageTargetQueryLatency)/float64(progress.AverageSourceQueryLatency),
+		progress.TargetToSourceLatencyRatio,
+		0.0001,
+	)
+}
+
+func (t *IterativeVerifierTestSuite) TestTracesBatches() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	tracer := &recordingTracer{}
+	t.verifier.Tracer = tracer
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	_, err = t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+
+	var reverifySpan *recordingSpan
+	for _, span := range tracer.spans {
+		if span.name == "iterative_verifier.reverify_batch" && span.attributes["phase"] == "iterative_verifier_during_cutover" {
+			reverifySpan = span
+		}
+	}
+
+	t.Require().NotNil(reverifySpan)
+	t.Require().True(reverifySpan.ended)
+	t.Require().Equal("gftest.test_table_1", reverifySpan.attributes["table"])
+	t.Require().Equal(1, reverifySpan.attributes["batch_size"])
+	t.Require().Equal(1, reverifySpan.attributes["mismatch_count"])
+
+	children := 0
+	for _, span := range tracer.spans {
+		if span.parent == reverifySpan {
+			t.Require().Equal("iterative_verifier.get_hashes", span.name)
+			t.Require().True(span.ended)
+			children++
+		}
+	}
+	t.Require().Equal(2, children)
+}
+
+func (t *IterativeVerifierTestSuite) TestBeforeCutoverResumesFromStateFile() {
+	stateDir, err := ioutil.TempDir("", "iterative_verifier_state")
+	t.Require().Nil(err)
+	defer os.RemoveAll(stateDir)
+
+	// Simulate a restart after test_table_1 completed its initial pass.
+	completedTable := ghostferry.TableIdentifier{SchemaName: testhelpers.TestSchemaName, TableName: testhelpers.TestTable1Name}
+	stateBytes, err := json.Marshal(ghostferry.IterativeVerifierState{
+		CompletedTables: []ghostferry.TableIdentifier{completedTable},
+	})
+	t.Require().Nil(err)
+
+	t.verifier.StateFile = filepath.Join(stateDir, "state.json")
+	t.Require().Nil(ioutil.WriteFile(t.verifier.StateFile, stateBytes, 0644))
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, t.Ferry.SourceDB)
+	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData2, t.Ferry.TargetDB)
+
+	err = t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{fmt.Sprintf("%s.%s", testhelpers.TestSchemaName, testhelpers.TestCompressedTable1Name)}, result.IncorrectTables)
+
+	stateBytes, err = ioutil.ReadFile(t.verifier.StateFile)
+	t.Require().Nil(err)
+
+	var state ghostferry.IterativeVerifierState
+	t.Require().Nil(json.Unmarshal(stateBytes, &state))
+	t.Require().Equal(2, len(state.CompletedTables))
+}
+
+func (t *IterativeVerifierTestSuite) TestBeforeCutoverResumesAfterARestartOnTheLastTable() {
+	stateDir, err := ioutil.TempDir("", "iterative_verifier_state")
+	t.Require().Nil(err)
+	defer os.RemoveAll(stateDir)
+
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err = db.Exec(fmt.Sprintf("CREATE TABLE %s.test_table_2 LIKE %s.%s", testhelpers.TestSchemaName, testhelpers.TestSchemaName, testhelpers.TestTable1Name))
+		t.Require().Nil(err)
+		_, err = db.Exec(fmt.Sprintf("INSERT INTO %s.test_table_2 VALUES (42, 'foo')", testhelpers.TestSchemaName))
+		t.Require().Nil(err)
+		t.InsertRowInDb(42, "foo", db)
+		t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, db)
+	}
+	t.reloadTables()
+
+	tables := []*ghostferry.TableSchema{
+		t.table,
+		t.verifier.TableSchemaCache.Get(testhelpers.TestSchemaName, testhelpers.TestCompressedTable1Name),
+		t.verifier.TableSchemaCache.Get(testhelpers.TestSchemaName, "test_table_2"),
+	}
+
+	// Records the tables fingerprinted by a verifier, and fails the
+	// fingerprint queries of the failedTables.
+	var fingerprintedTables map[string]bool
+	var failedTables map[string]bool
+	var mutex sync.Mutex
+	queryRewriter := func(query string, args []interface{}) (string, []interface{}) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if !strings.Contains(query, "AS row_fingerprint") {
+			return query, args
+		}
+
+		for _, table := range tables {
+			if !strings.Contains(query, fmt.Sprintf("FROM `%s`.`%s`", table.Schema, table.Name)) {
+				continue
+			}
+
+			fingerprintedTables[table.Name] = true
+			if failedTables[table.Name] {
+				return "SELECT failed", args
+			}
+		}
+		return query, args
+	}
+
+	// The first process stops on an error of the last table, once the first
+	// two tables completed.
+	fingerprintedTables = map[string]bool{}
+	failedTables = map[string]bool{"test_table_2": true}
+	t.verifier.Tables = tables
+	t.verifier.StateFile = filepath.Join(stateDir, "state.json")
+	t.verifier.QueryRewriter = queryRewriter
+	t.Require().NotNil(t.verifier.VerifyBeforeCutover())
+	t.Require().Equal(map[string]bool{
+		testhelpers.TestTable1Name:           true,
+		testhelpers.TestCompressedTable1Name: true,
+		"test_table_2":                       true,
+	}, fingerprintedTables)
+
+	// The restarted process only fingerprints the last table again.
+	restarted := &ghostferry.IterativeVerifier{
+		CompressionVerifier: t.verifier.CompressionVerifier,
+		CursorConfig:        t.verifier.CursorConfig,
+		BinlogStreamer:      t.Ferry.BinlogStreamer,
+		SourceDB:            t.Ferry.SourceDB,
+		TargetDB:            t.Ferry.TargetDB,
+		Tables:              tables,
+		TableSchemaCache:    t.verifier.TableSchemaCache,
+		StateFile:           t.verifier.StateFile,
+		QueryRewriter:       queryRewriter,
+		Concurrency:         1,
+	}
+	t.Require().Nil(restarted.Initialize())
+
+	fingerprintedTables = map[string]bool{}
+	failedTables = map[string]bool{}
+	t.Require().Nil(restarted.VerifyBeforeCutover())
+	t.Require().Equal(map[string]bool{"test_table_2": true}, fingerprintedTables)
+
+	result, err := restarted.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithTableNamePrefix() {
+	prefixedTableName := "prod_" + testhelpers.TestTable1Name
+	_, err := t.Ferry.TargetDB.Exec(fmt.Sprintf("CREATE TABLE %s.%s LIKE %s.%s", testhelpers.TestSchemaName, prefixedTableName, testhelpers.TestSchemaName, testhelpers.TestTable1Name))
+	t.Require().Nil(err)
+
+	t.verifier.TableNamePrefix = "prod_"
+	// The explicit rewrite takes precedence over the prefix.
+	t.verifier.TableRewrites = map[string]string{testhelpers.TestCompressedTable1Name: testhelpers.TestCompressedTable1Name}
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	_, err = t.Ferry.TargetDB.Exec(fmt.Sprintf("INSERT INTO %s.%s VALUES (42, 'foo')", testhelpers.TestSchemaName, prefixedTableName))
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	_, err = t.Ferry.TargetDB.Exec(fmt.Sprintf("UPDATE %s.%s SET data = 'bar' WHERE id = 42", testhelpers.TestSchemaName, prefixedTableName))
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyDuringCutoverWithRewrittenTable() {
+	rewrittenTableName := testhelpers.TestTable1Name + "_rewritten"
+	_, err := t.Ferry.TargetDB.Exec(fmt.Sprintf("CREATE TABLE %s.%s LIKE %s.%s", testhelpers.TestSchemaName, rewrittenTableName, testhelpers.TestSchemaName, testhelpers.TestTable1Name))
+	t.Require().Nil(err)
+
+	t.verifier.TableRewrites = map[string]string{testhelpers.TestTable1Name: rewrittenTableName}
+
+	stateDir, err := ioutil.TempDir("", "iterative_verifier_state")
+	t.Require().Nil(err)
+	defer os.RemoveAll(stateDir)
+
+	// The batch to reverify names the table as it was rewritten on the target.
+	stateBytes, err := json.Marshal(ghostferry.IterativeVerifierState{
+		CompletedTables: []ghostferry.TableIdentifier{
+			{SchemaName: testhelpers.TestSchemaName, TableName: testhelpers.TestTable1Name},
+			{SchemaName: testhelpers.TestSchemaName, TableName: testhelpers.TestCompressedTable1Name},
+		},
+		ReverifyStore: []ghostferry.PersistedReverifyBatch{
+			{
+				Table: ghostferry.TableIdentifier{SchemaName: testhelpers.TestSchemaName, TableName: rewrittenTableName},
+				Keys:  []string{"42"},
+			},
+		},
+	})
+	t.Require().Nil(err)
+
+	t.verifier.StateFile = filepath.Join(stateDir, "state.json")
+	t.Require().Nil(ioutil.WriteFile(t.verifier.StateFile, stateBytes, 0644))
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	_, err = t.Ferry.TargetDB.Exec(fmt.Sprintf("INSERT INTO %s.%s VALUES (42, 'bar')", testhelpers.TestSchemaName, rewrittenTableName))
+	t.Require().Nil(err)
+
+	err = t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{fmt.Sprintf("%s.%s", testhelpers.TestSchemaName, testhelpers.TestTable1Name)}, result.IncorrectTables)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyDuringCutoverResumesFromStateFile() {
+	stateDir, err := ioutil.TempDir("", "iterative_verifier_state")
+	t.Require().Nil(err)
+	defer os.RemoveAll(stateDir)
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	// The interrupted cutover verification may have found the batch of row
+	// 42 to match before the row was changed while the process was not
+	// running, so that it is reverified along with row 43.
+	table := ghostferry.TableIdentifier{SchemaName: testhelpers.TestSchemaName, TableName: testhelpers.TestTable1Name}
+	state := ghostferry.IterativeVerifierState{
+		CutoverBatches: []ghostferry.PersistedReverifyBatch{
+			{Table: table, Keys: []string{"42"}},
+			{Table: table, Keys: []string{"43"}},
+		},
+	}
+	for _, table := range t.verifier.Tables {
+		state.CompletedTables = append(state.CompletedTables, ghostferry.NewTableIdentifierFromSchemaTable(table))
+	}
+
+	stateBytes, err := json.Marshal(state)
+	t.Require().Nil(err)
+
+	t.verifier.StateFile = filepath.Join(stateDir, "state.json")
+	t.Require().Nil(ioutil.WriteFile(t.verifier.StateFile, stateBytes, 0644))
+
+	err = t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	sort.Slice(result.Mismatches, func(i, j int) bool { return result.Mismatches[i].PaginationKey < result.Mismatches[j].PaginationKey })
+	t.Require().Equal([]ghostferry.VerificationMismatch{
+		ghostferry.NewVerificationMismatch(table, 42),
+		ghostferry.NewVerificationMismatch(table, 43),
+	}, result.Mismatches)
+
+	stateBytes, err = ioutil.ReadFile(t.verifier.StateFile)
+	t.Require().Nil(err)
+	state = ghostferry.IterativeVerifierState{}
+	t.Require().Nil(json.Unmarshal(stateBytes, &state))
+	t.Require().Equal(2, len(state.CutoverBatches))
+
+	// The batches are forgotten once the data is found to be correct.
+	t.UpdateRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.UpdateRowInDb(43, "foo", t.Ferry.TargetDB)
+
+	result, err = t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	stateBytes, err = ioutil.ReadFile(t.verifier.StateFile)
+	t.Require().Nil(err)
+	state = ghostferry.IterativeVerifierState{}
+	t.Require().Nil(json.Unmarshal(stateBytes, &state))
+	t.Require().Empty(state.CutoverBatches)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyDuringCutoverWithUnknownTable() {
+	stateDir, err := ioutil.TempDir("", "iterative_verifier_state")
+	t.Require().Nil(err)
+	defer os.RemoveAll(stateDir)
+
+	stateBytes, err := json.Marshal(ghostferry.IterativeVerifierState{
+		ReverifyStore: []ghostferry.PersistedReverifyBatch{
+			{
+				Table: ghostferry.TableIdentifier{SchemaName: testhelpers.TestSchemaName, TableName: "unknown_table"},
+				Keys:  []string{"42"},
+			},
+		},
+	})
+	t.Require().Nil(err)
+
+	t.verifier.StateFile = filepath.Join(stateDir, "state.json")
+	t.Require().Nil(ioutil.WriteFile(t.verifier.StateFile, stateBytes, 0644))
+
+	err = t.verifier.VerifyBeforeCutover()
+	t.Require().NotNil(err)
+	t.Require().Equal(fmt.Sprintf("cannot reverify table %s.unknown_table: table is not in the table schema cache", testhelpers.TestSchemaName), err.Error())
+}
+
+func (t *IterativeVerifierTestSuite) TestExpectedMismatchesDoNotFailDuringCutover() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	table := ghostferry.TableIdentifier{SchemaName: testhelpers.TestSchemaName, TableName: testhelpers.TestTable1Name}
+	t.verifier.ExpectedMismatches = map[ghostferry.TableIdentifier][]uint64{
+		table: []uint64{42, 43},
+	}
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestUnexpectedMismatchesFailDuringCutover() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	table := ghostferry.TableIdentifier{SchemaName: testhelpers.TestSchemaName, TableName: testhelpers.TestTable1Name}
+	t.verifier.ExpectedMismatches = map[ghostferry.TableIdentifier][]uint64{
+		table: []uint64{42},
+	}
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]ghostferry.VerificationMismatch{ghostferry.NewVerificationMismatch(table, 43)}, result.Mismatches)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyDuringCutoverRepairsMismatchedRows() {
+	t.verifier.EnableRepair = true
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(44, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(44, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	// 44 now only exists on the target, so the repair deletes it.
+	_, err = t.Ferry.SourceDB.Exec("DELETE FROM gftest.test_table_1 WHERE id = 44")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Empty(result.Mismatches)
+
+	repairedPaginationKeys := make([]uint64, 0)
+	for _, mismatch := range result.RepairedMismatches {
+		repairedPaginationKeys = append(repairedPaginationKeys, mismatch.PaginationKey)
+	}
+	t.Require().ElementsMatch([]uint64{42, 43, 44}, repairedPaginationKeys)
+
+	var count int
+	err = t.Ferry.TargetDB.QueryRow("SELECT COUNT(*) FROM gftest.test_table_1 WHERE id = 44").Scan(&count)
+	t.Require().Nil(err)
+	t.Require().Equal(0, count)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceRepairsMismatchedRows() {
+	t.verifier.EnableRepair = true
+	t.verifier.RepairDryRun = true
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Empty(result.RepairedMismatches)
+
+	t.verifier.RepairDryRun = false
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal(2, len(result.RepairedMismatches))
+
+	var data string
+	err = t.Ferry.TargetDB.QueryRow("SELECT data FROM gftest.test_table_1 WHERE id = 42").Scan(&data)
+	t.Require().Nil(err)
+	t.Require().Equal("foo", data)
+}
+
+func (t *IterativeVerifierTestSuite) TestRepairKeepsRowsReferencingRepairedRows() {
+	t.verifier.EnableRepair = true
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	_, err := t.Ferry.TargetDB.Exec("CREATE TABLE gftest.child_table (id bigint PRIMARY KEY, parent_id bigint, FOREIGN KEY (parent_id) REFERENCES gftest.test_table_1 (id) ON DELETE CASCADE)")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.child_table VALUES (1, 42)")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal(1, len(result.RepairedMismatches))
+
+	var data string
+	err = t.Ferry.TargetDB.QueryRow("SELECT data FROM gftest.test_table_1 WHERE id = 42").Scan(&data)
+	t.Require().Nil(err)
+	t.Require().Equal("foo", data)
+
+	var count int
+	err = t.Ferry.TargetDB.QueryRow("SELECT COUNT(*) FROM gftest.child_table WHERE parent_id = 42").Scan(&count)
+	t.Require().Nil(err)
+	t.Require().Equal(1, count)
+}
+
+func (t *IterativeVerifierTestSuite) TestRepairSkipsMergedTables() {
+	t.verifier.EnableRepair = true
+
+	// The target table also holds the rows merged from another source table,
+	// whose paginationKeys overlap with the ones of the table.
+	_, err := t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN origin VARCHAR(16) NOT NULL DEFAULT 'table_1', DROP PRIMARY KEY, ADD PRIMARY KEY (id, origin)")
+	t.Require().Nil(err)
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, \"bar\", 'table_1'), (42, \"baz\", 'table_2'), (43, \"baz\", 'table_2')")
+	t.Require().Nil(err)
+
+	t.verifier.MergedTablePredicates = map[string]string{testhelpers.TestTable1Name: "origin = 'table_1'"}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Empty(result.RepairedMismatches)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 42", result.Message)
+
+	rows, err := t.Ferry.TargetDB.Query("SELECT id, data, origin FROM gftest.test_table_1 ORDER BY id, origin")
+	t.Require().Nil(err)
+	defer rows.Close()
+
+	var targetRows []string
+	for rows.Next() {
+		var id uint64
+		var data, origin string
+		t.Require().Nil(rows.Scan(&id, &data, &origin))
+		targetRows = append(targetRows, fmt.Sprintf("%d %s %s", id, data, origin))
+	}
+	t.Require().Nil(rows.Err())
+	t.Require().Equal([]string{"42 bar table_1", "42 baz table_2", "43 baz table_2"}, targetRows)
+}
+
+func (t *IterativeVerifierTestSuite) TestTargetIsSuperset() {
+	t.verifier.TargetIsSuperset = true
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+	t.InsertRowInDb(44, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(44, "bar", t.Ferry.TargetDB)
+	t.InsertRowInDb(1000, "from another source", t.Ferry.TargetDB)
+	t.InsertRowInDb(1001, "from another source", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	// Both mismatched rows are reverified during cutover, at which point 43
+	// only exists on the target while 44 still differs.
+	_, err = t.Ferry.SourceDB.Exec("DELETE FROM gftest.test_table_1 WHERE id = 43")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.IncorrectTables)
+	t.Require().Equal(1, len(result.Mismatches))
+	t.Require().Equal(uint64(44), result.Mismatches[0].PaginationKey)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceSkipsUnchangedTables() {
+	signatureDir, err := ioutil.TempDir("", "iterative_verifier_signatures")
+	t.Require().Nil(err)
+	defer os.RemoveAll(signatureDir)
+
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN updated_at DATETIME(6) NOT NULL DEFAULT '2020-01-01' ON UPDATE CURRENT_TIMESTAMP(6)")
+		t.Require().Nil(err)
+		_, err = db.Exec("INSERT INTO gftest.test_table_1 (id, data) VALUES (42, 'foo')")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+
+	t.verifier.Tables = []*ghostferry.TableSchema{t.table}
+	t.verifier.TableSignatureFile = filepath.Join(signatureDir, "signatures.json")
+	t.verifier.ModificationTimestampColumns = map[string]string{testhelpers.TestTable1Name: "updated_at"}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	signatureBytes, err := ioutil.ReadFile(t.verifier.TableSignatureFile)
+	t.Require().Nil(err)
+
+	var signatures map[string]ghostferry.VerifiedTableSignature
+	t.Require().Nil(json.Unmarshal(signatureBytes, &signatures))
+	expected := ghostferry.TableSignature{RowCount: 1, MaxPaginationKey: 42, MaxModificationTime: "2020-01-01 00:00:00.000000"}
+	t.Require().Equal(expected, signatures["gftest.test_table_1"].Source)
+	t.Require().Equal(expected, signatures["gftest.test_table_1"].Target)
+
+	// The signature is unchanged: the table is skipped.
+	t.verifier.Reset()
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal(uint64(0), t.verifier.Progress().RowsVerified)
+
+	// An in-place update changes the latest modification time: the table is
+	// verified again.
+	t.UpdateRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	t.verifier.Reset()
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.IncorrectTables)
+}
+
+func (t *IterativeVerifierTestSuite) TestRejectsTableSignaturesWithoutModificationTimestamp() {
+	t.verifier.Tables = []*ghostferry.TableSchema{t.table}
+	t.verifier.TableSignatureFile = "signatures.json"
+
+	err := t.verifier.Initialize()
+	t.Require().EqualError(err, "TableSignatureFile requires a ModificationTimestampColumns entry for table gftest.test_table_1, as its rows updated in place would otherwise not be detected")
+}
+
+func (t *IterativeVerifierTestSuite) TestTargetOnlyRowsExpected() {
+	t.verifier.TargetOnlyRowsExpected = map[string]bool{testhelpers.TestTable1Name: true}
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	// Both mismatched rows are reverified during cutover, at which point
+	// 43 only exists on the target.
+	_, err = t.Ferry.SourceDB.Exec("DELETE FROM gftest.test_table_1 WHERE id = 43")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	table := ghostferry.TableIdentifier{SchemaName: testhelpers.TestSchemaName, TableName: testhelpers.TestTable1Name}
+	t.Require().Equal([]ghostferry.VerificationMismatch{ghostferry.NewVerificationMismatch(table, 42)}, result.Mismatches)
+}
+
+func (t *IterativeVerifierTestSuite) TestBeforeCutoverFailuresPassDuringCutover() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	t.UpdateRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal("", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestBatchChecksumShortCircuitFindsMismatchedRow() {
+	t.verifier.BatchChecksumShortCircuit = true
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestBatchChecksumShortCircuitPasses() {
+	t.verifier.BatchChecksumShortCircuit = true
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestBatchChecksumChangesWithData() {
+	t.InsertRow(42, "foo")
+	t.InsertRow(43, "bar")
+
+	before, err := t.verifier.GetBatchChecksum(t.db, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, []uint64{42, 43})
+	t.Require().Nil(err)
+	t.Require().Equal(uint64(2), before.RowCount)
+
+	t.UpdateRow(43, "baz")
+	after, err := t.verifier.GetBatchChecksum(t.db, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, []uint64{42, 43})
+	t.Require().Nil(err)
+	t.Require().Equal(uint64(2), after.RowCount)
+	t.Require().NotEqual(before.Checksum, after.Checksum)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithCompareColumnDefaults() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN status varchar(16) DEFAULT 'active'")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+
+	t.verifier.CompareColumnDefaults = true
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	_, err = t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 ALTER COLUMN status DROP DEFAULT")
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyOnce()
 	t.Require().Nil(err)
 	t.Require().False(result.DataCorrect)
-	t.Require().Equal(
-		fmt.Sprintf("verification failed on table: %s.%s for paginationKey: %s", testhelpers.TestSchemaName, testhelpers.TestCompressedTable1Name, "42"),
-		result.Message,
-	)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.IncorrectTables)
+	t.Require().Equal("column defaults differ: default of column status of table gftest.test_table_1 is 'active' on the source but NULL on the target", result.Message)
 }
 
-func (t *IterativeVerifierTestSuite) TestVerifyOncePass() {
+func (t *IterativeVerifierTestSuite) TestVerifyOnceMergesTheResultsOfTheTargetChecks() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN status varchar(16) DEFAULT 'active'")
+		t.Require().Nil(err)
+	}
+	_, err := t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 ALTER COLUMN status DROP DEFAULT")
+	t.Require().Nil(err)
+	t.reloadTables()
+
 	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
 	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(50, "garbage", t.Ferry.TargetDB)
+
+	t.verifier.CompareColumnDefaults = true
+	t.verifier.CheckTargetMaxPaginationKey = true
 
 	result, err := t.verifier.VerifyOnce()
-	t.Require().NotNil(result)
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.IncorrectTables)
+	t.Require().Equal("column defaults differ: default of column status of table gftest.test_table_1 is 'active' on the source but NULL on the target; target has unexpected rows: 1 rows of table gftest.test_table_1 on the target have paginationKeys above 42, the largest on the source, up to 50", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceDetectsValuesShiftedAcrossColumns() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN data2 TEXT")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+
+	for _, row := range [][]interface{}{{42, "", "x"}, {43, "a", "bc"}} {
+		_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.test_table_1 (id, data, data2) VALUES (?, ?, ?)", row...)
+		t.Require().Nil(err)
+	}
+
+	for _, row := range [][]interface{}{{42, "x", ""}, {43, "ab", "c"}} {
+		_, err := t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 (id, data, data2) VALUES (?, ?, ?)", row...)
+		t.Require().Nil(err)
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 42", result.Message)
+
+	// VerifyOnce stops at the first mismatch, unlike the verification of a
+	// range, which also reports the values concatenating to the same string.
+	result, err = t.verifier.VerifyPaginationKeyRange(t.table, 42, 44)
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	mismatchedPaginationKeys := make([]uint64, 0, len(result.Mismatches))
+	for _, mismatch := range result.Mismatches {
+		mismatchedPaginationKeys = append(mismatchedPaginationKeys, mismatch.PaginationKey)
+	}
+	t.Require().ElementsMatch([]uint64{42, 43}, mismatchedPaginationKeys)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithVerifyWhere() {
+	t.InsertRowInDb(42, "active", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "archived", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "active", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "archived-differently", t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	t.verifier.VerifyWhere = map[string]string{testhelpers.TestTable1Name: "data = 'active'"}
+
+	result, err = t.verifier.VerifyOnce()
 	t.Require().Nil(err)
 	t.Require().True(result.DataCorrect)
-	t.Require().Equal("", result.Message)
+
+	t.UpdateRowInDb(42, "activ", t.Ferry.TargetDB)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 42", result.Message)
 }
 
-func (t *IterativeVerifierTestSuite) TestVerifyCompressedOncePass() {
-	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, t.Ferry.SourceDB)
-	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, t.Ferry.TargetDB)
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithAggregates() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(44, "baz", t.Ferry.TargetDB)
+
+	t.verifier.Aggregates = map[string][]ghostferry.Aggregate{
+		testhelpers.TestTable1Name: []ghostferry.Aggregate{
+			ghostferry.Aggregate{Function: "COUNT", Column: "*"},
+			ghostferry.Aggregate{Function: "MAX", Column: "id"},
+		},
+	}
+	t.verifier.AggregatesOnly = true
 
 	result, err := t.verifier.VerifyOnce()
-	t.Require().NotNil(result)
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.IncorrectTables)
+	t.Require().Equal("aggregates differ: MAX(id) of table gftest.test_table_1 is 43 on the source but 44 on the target", result.Message)
+	t.Require().Empty(result.Mismatches)
+
+	t.verifier.Aggregates[testhelpers.TestTable1Name] = t.verifier.Aggregates[testhelpers.TestTable1Name][:1]
+
+	result, err = t.verifier.VerifyOnce()
 	t.Require().Nil(err)
 	t.Require().True(result.DataCorrect)
-	t.Require().Equal("", result.Message)
 }
 
-func (t *IterativeVerifierTestSuite) TestVerifyDifferentCompressedSameDecompressedDataOncePass() {
-	t.Require().NotEqual(testhelpers.TestCompressedData3, testhelpers.TestCompressedData4)
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithDistributionColumns() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
 
-	t.InsertCompressedRowInDb(43, testhelpers.TestCompressedData3, t.Ferry.SourceDB)
-	t.InsertCompressedRowInDb(43, testhelpers.TestCompressedData4, t.Ferry.TargetDB)
+	// The rows are not fingerprinted, so that only the distributions are
+	// compared.
+	t.verifier.AggregatesOnly = true
+	t.verifier.Aggregates = map[string][]ghostferry.Aggregate{
+		testhelpers.TestTable1Name: []ghostferry.Aggregate{ghostferry.Aggregate{Function: "COUNT", Column: "*"}},
+	}
+	t.verifier.DistributionColumns = map[string][]string{testhelpers.TestTable1Name: []string{"data"}}
 
 	result, err := t.verifier.VerifyOnce()
-	t.Require().NotNil(result)
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"gftest.test_table_1"}, result.IncorrectTables)
+	t.Require().Equal("distributions differ: data = bar of table gftest.test_table_1 has 0 rows on the source but 1 on the target; "+
+		"data = foo of table gftest.test_table_1 has 2 rows on the source but 1 on the target", result.Message)
+
+	// The rows not matching the VerifyWhere are not counted.
+	t.verifier.VerifyWhere = map[string]string{testhelpers.TestTable1Name: "id < 43"}
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	t.verifier.VerifyWhere = nil
+	t.UpdateRowInDb(43, "foo", t.Ferry.TargetDB)
+
+	result, err = t.verifier.VerifyOnce()
 	t.Require().Nil(err)
 	t.Require().True(result.DataCorrect)
-	t.Require().Equal("", result.Message)
 }
 
-func (t *IterativeVerifierTestSuite) TestBeforeCutoverFailuresFailAgainDuringCutover() {
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithSystemVersionedTable() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("CREATE TABLE gftest.versioned_table (id bigint(20) unsigned NOT NULL, data TEXT, " +
+			"row_start TIMESTAMP(6) GENERATED ALWAYS AS ROW START, row_end TIMESTAMP(6) GENERATED ALWAYS AS ROW END, " +
+			"PERIOD FOR SYSTEM_TIME(row_start, row_end), PRIMARY KEY (id)) WITH SYSTEM VERSIONING")
+		if err != nil {
+			t.T().Skipf("system-versioned tables are not supported: %v", err)
+		}
+	}
+
+	// The rows are written at different times, so the period columns differ.
+	// The historical row of the update is only on the source.
+	_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.versioned_table (id, data) VALUES (42, 'foo')")
+	t.Require().Nil(err)
+	_, err = t.Ferry.SourceDB.Exec("UPDATE gftest.versioned_table SET data = 'bar' WHERE id = 42")
+	t.Require().Nil(err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.versioned_table (id, data) VALUES (42, 'bar')")
+	t.Require().Nil(err)
+
+	t.reloadTables()
+	t.verifier.ExcludeSystemVersioningColumns = true
+	t.Require().Nil(t.verifier.Initialize())
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	_, err = t.Ferry.TargetDB.Exec("UPDATE gftest.versioned_table SET data = 'baz' WHERE id = 42")
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.versioned_table for paginationKey: 42", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestExcludeSystemVersioningColumns() {
 	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
 	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
 
-	err := t.verifier.VerifyBeforeCutover()
+	// MySQL has no system-versioned tables, so that the lookup is faked to
+	// make the data column a period column.
+	lookups := 0
+	fakePeriodColumns := false
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		if !strings.Contains(query, "SYSTEM VERSIONED") {
+			return query, args
+		}
+
+		lookups++
+		if fakePeriodColumns {
+			return "SELECT 'gftest', 'test_table_1', 'data'", args
+		}
+		return query, args
+	}
+
+	// The period columns are only looked up when enabled.
+	t.Require().Nil(t.verifier.Initialize())
+	t.Require().Equal(0, lookups)
+
+	t.verifier.ExcludeSystemVersioningColumns = true
+	t.Require().Nil(t.verifier.Initialize())
+	t.Require().Equal(1, lookups)
+
+	result, err := t.verifier.VerifyOnce()
 	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
 
-	result, err := t.verifier.VerifyDuringCutover()
+	fakePeriodColumns = true
+	t.Require().Nil(t.verifier.Initialize())
+	t.Require().Equal(2, lookups)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithQueryRewriter() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	var queries []string
+	queriesMutex := &sync.Mutex{}
+	t.verifier.QueryRewriter = func(sql string, args []interface{}) (string, []interface{}) {
+		sql = "/* ghostferry_verifier */ " + sql
+		queriesMutex.Lock()
+		queries = append(queries, sql)
+		queriesMutex.Unlock()
+		return sql, args
+	}
+
+	result, err := t.verifier.VerifyOnce()
 	t.Require().Nil(err)
 	t.Require().False(result.DataCorrect)
-	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKeys: 42", result.Message)
+
+	t.Require().True(len(queries) >= 2)
+	for _, query := range queries {
+		t.Require().Regexp("^/\\* ghostferry_verifier \\*/ SELECT ", query)
+	}
 }
 
-func (t *IterativeVerifierTestSuite) TestBeforeCutoverCompressionFailuresFailAgainDuringCutover() {
-	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData1, t.Ferry.SourceDB)
-	t.InsertCompressedRowInDb(42, testhelpers.TestCompressedData2, t.Ferry.TargetDB)
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithDutyCycle() {
+	for id := 40; id < 46; id++ {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "foo", t.Ferry.TargetDB)
+	}
 
-	err := t.verifier.VerifyBeforeCutover()
+	// Each batch takes at least as long as a single fingerprint query.
+	queryDuration := 50 * time.Millisecond
+	t.verifier.QueryRewriter = func(sql string, args []interface{}) (string, []interface{}) {
+		time.Sleep(queryDuration)
+		return sql, args
+	}
+	t.verifier.CursorConfig.BatchSize = 2
+	t.verifier.DutyCycle = 0.5
+
+	start := time.Now()
+	result, err := t.verifier.VerifyOnce()
 	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
 
-	result, err := t.verifier.VerifyDuringCutover()
+	// The verification rests for as long as it works after each of the 3
+	// batches.
+	t.Require().True(time.Now().Sub(start) >= 3*2*queryDuration)
+
+	t.verifier.DutyCycle = 1.5
+	t.Require().EqualError(t.verifier.Initialize(), "DutyCycle must be between 0 and 1, not 1.5")
+
+	t.verifier.DutyCycle = -0.5
+	t.Require().EqualError(t.verifier.Initialize(), "DutyCycle must be between 0 and 1, not -0.5")
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithMaxPreparedStatementsPerDB() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("CREATE TABLE gftest.test_table_2 LIKE gftest.test_table_1")
+		t.Require().Nil(err)
+
+		for id := 42; id < 52; id++ {
+			_, err = db.Exec("INSERT INTO gftest.test_table_2 VALUES (?, \"foo\")", id)
+			t.Require().Nil(err)
+		}
+	}
+	t.reloadTables()
+
+	for id := 42; id < 52; id++ {
+		t.InsertRowInDb(id, "foo", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "foo", t.Ferry.TargetDB)
+	}
+	t.UpdateRowInDb(50, "bar", t.Ferry.TargetDB)
+
+	// Both databases fail to prepare more than one statement at a time, so
+	// that the cursors and the fingerprint queries of the two tables must
+	// queue for the single prepared statement of each database.
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		var maxPreparedStatements int
+		t.Require().Nil(db.QueryRow("SELECT @@GLOBAL.max_prepared_stmt_count").Scan(&maxPreparedStatements))
+
+		_, err := db.Exec("SET GLOBAL max_prepared_stmt_count = 1")
+		t.Require().Nil(err)
+
+		defer func(db *sql.DB) {
+			_, err := db.Exec(fmt.Sprintf("SET GLOBAL max_prepared_stmt_count = %d", maxPreparedStatements))
+			t.Require().Nil(err)
+		}(db)
+	}
+
+	t.verifier.Concurrency = 4
+	t.verifier.CursorConfig.BatchSize = 2
+	t.verifier.MaxPreparedStatementsPerDB = 1
+	t.verifier.QueryLimiter = nil
+	t.Require().Nil(t.verifier.Initialize())
+
+	result, err := t.verifier.VerifyOnce()
 	t.Require().Nil(err)
 	t.Require().False(result.DataCorrect)
-	t.Require().Equal(fmt.Sprintf("verification failed on table: %s.%s for paginationKeys: %s", "gftest", testhelpers.TestCompressedTable1Name, "42"), result.Message)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 50", result.Message)
+
+	t.verifier.MaxPreparedStatementsPerDB = -1
+	t.Require().EqualError(t.verifier.Initialize(), "MaxPreparedStatementsPerDB must not be negative, not -1")
 }
 
-func (t *IterativeVerifierTestSuite) TestBeforeCutoverDifferentCompressedSameDecompressedDataPassDuringCutover() {
-	t.Require().NotEqual(testhelpers.TestCompressedData3, testhelpers.TestCompressedData4)
+func (t *IterativeVerifierTestSuite) TestCountsQueriesExaminingExcessRows() {
+	t.addExternalIdColumn()
+	for id := 42; id < 62; id++ {
+		for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+			_, err := db.Exec("INSERT INTO gftest.test_table_1 VALUES (?, \"foo\", ?)", id, id)
+			t.Require().Nil(err)
+		}
+	}
 
-	t.InsertCompressedRowInDb(43, testhelpers.TestCompressedData3, t.Ferry.SourceDB)
-	t.InsertCompressedRowInDb(43, testhelpers.TestCompressedData4, t.Ferry.TargetDB)
+	t.verifier.CursorConfig.BatchSize = 2
+	t.verifier.ExaminedRowsWarningRatio = 2
 
-	err := t.verifier.VerifyBeforeCutover()
+	result, err := t.verifier.VerifyOnce()
 	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+	t.Require().Equal(uint64(0), t.verifier.Progress().QueriesExaminingExcessRows)
 
-	result, err := t.verifier.VerifyDuringCutover()
+	// The external_id column is not indexed, so that every batch scans the
+	// table. The query of each side is explained once rather than for every
+	// batch, through the QueryRewriter as any read query.
+	t.verifier.VerificationKeyColumns = map[string]string{testhelpers.TestTable1Name: "external_id"}
+
+	explained := int32(0)
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		if strings.HasPrefix(query, "EXPLAIN ") {
+			atomic.AddInt32(&explained, 1)
+		}
+		return query, args
+	}
+
+	result, err = t.verifier.VerifyOnce()
 	t.Require().Nil(err)
 	t.Require().True(result.DataCorrect)
-	t.Require().Equal("", result.Message)
+	t.Require().Equal(uint64(2), t.verifier.Progress().QueriesExaminingExcessRows)
+	t.Require().Equal(int32(2), atomic.LoadInt32(&explained))
 }
 
-func (t *IterativeVerifierTestSuite) TestErrorsIfMaxDowntimeIsSurpassed() {
+func (t *IterativeVerifierTestSuite) TestVerifyWithSharedQueryLimiter() {
 	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
 	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
 
-	t.verifier.MaxExpectedDowntime = 1 * time.Nanosecond
-	err := t.verifier.VerifyBeforeCutover()
-	t.Require().Regexp("cutover stage verification will not complete within max downtime duration \\(took .*\\)", err.Error())
+	limiter := ghostferry.NewQueryLimiter(1)
+	t.verifier.QueryLimiter = limiter
+
+	otherVerifier := *t.verifier
+	t.Require().Nil(otherVerifier.Initialize())
+	t.Require().Equal(limiter, otherVerifier.QueryLimiter)
+
+	wg := &sync.WaitGroup{}
+	results := make([]ghostferry.VerificationResult, 2)
+	errs := make([]error, 2)
+	for idx, verifier := range []*ghostferry.IterativeVerifier{t.verifier, &otherVerifier} {
+		wg.Add(1)
+		go func(idx int, verifier *ghostferry.IterativeVerifier) {
+			defer wg.Done()
+			results[idx], errs[idx] = verifier.VerifyOnce()
+		}(idx, verifier)
+	}
+	wg.Wait()
+
+	for idx := range results {
+		t.Require().Nil(errs[idx])
+		t.Require().False(results[idx].DataCorrect)
+		t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 42", results[idx].Message)
+	}
 }
 
-func (t *IterativeVerifierTestSuite) TestBeforeCutoverFailuresPassDuringCutover() {
+func (t *IterativeVerifierTestSuite) TestLogsBatchIds() {
 	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
 	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
 
+	hook := &entriesHook{}
+	logger := logrus.StandardLogger()
+	oldHooks := logger.Hooks
+	logger.Hooks = make(logrus.LevelHooks)
+	logger.Hooks.Add(hook)
+	defer func() { logger.Hooks = oldHooks }()
+
+	tracer := &recordingTracer{}
+	t.verifier.Tracer = tracer
+
 	err := t.verifier.VerifyBeforeCutover()
 	t.Require().Nil(err)
 
-	t.UpdateRowInDb(42, "foo", t.Ferry.TargetDB)
-
 	result, err := t.verifier.VerifyDuringCutover()
 	t.Require().Nil(err)
-	t.Require().True(result.DataCorrect)
-	t.Require().Equal("", result.Message)
+	t.Require().False(result.DataCorrect)
+
+	batchIds := make(map[string]string)
+	for _, entry := range hook.entries {
+		if entry.Message == "found mismatched rows" || entry.Message == "failed reverification: "+result.Message {
+			batchIds[entry.Message] = entry.Data["batch_id"].(string)
+		}
+	}
+	t.Require().Equal(2, len(batchIds))
+	t.Require().NotEqual(batchIds["found mismatched rows"], batchIds["failed reverification: "+result.Message])
+
+	for _, span := range tracer.spans {
+		if span.name == "iterative_verifier.reverify_batch" && span.attributes["phase"] == "iterative_verifier_during_cutover" {
+			t.Require().Equal(batchIds["failed reverification: "+result.Message], span.attributes["batch_id"])
+		}
+	}
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithTargetFingerprintSource() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	// Export the fingerprints of the target and drop its data, so the
+	// verification can only succeed by using the export.
+	hashes, err := t.verifier.GetHashes(t.Ferry.TargetDB, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, []uint64{42, 43})
+	t.Require().Nil(err)
+
+	dir, err := ioutil.TempDir("", "fingerprint_source")
+	t.Require().Nil(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test_table_1.csv")
+	export := fmt.Sprintf("42,%s\n43,%s\n", hashes[42], hashes[43])
+	t.Require().Nil(ioutil.WriteFile(path, []byte(export), 0644))
+
+	_, err = t.Ferry.TargetDB.Exec("DELETE FROM gftest.test_table_1")
+	t.Require().Nil(err)
+
+	t.verifier.IgnoredTables = []string{testhelpers.TestCompressedTable1Name}
+	t.verifier.TargetFingerprintSource = &ghostferry.FingerprintFileSource{
+		Files: map[string]string{testhelpers.TestTable1Name: path},
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 43", result.Message)
+}
+
+func (t *IterativeVerifierTestSuite) TestTargetFingerprintSourceRejectsOptionsRequiringALiveTarget() {
+	t.verifier.TargetFingerprintSource = &ghostferry.FingerprintFileSource{}
+	t.Require().Nil(t.verifier.Initialize())
+
+	t.verifier.ShardCount = 2
+	t.Require().EqualError(t.verifier.Initialize(), "ShardCount is not supported with a TargetFingerprintSource")
+
+	t.verifier.ShardCount = 0
+	t.verifier.WaitForSourceGtid = true
+	t.Require().EqualError(t.verifier.Initialize(), "WaitForSourceGtid is not supported with a TargetFingerprintSource")
+
+	t.verifier.TargetFingerprintSource = nil
+	t.Require().Nil(t.verifier.Initialize())
+}
+
+func (t *IterativeVerifierTestSuite) TestFingerprintErrorsDescribeTheBatch() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+
+	t.verifier.IgnoredTables = []string{testhelpers.TestCompressedTable1Name}
+	t.verifier.TargetFingerprintSource = &ghostferry.FingerprintFileSource{
+		Files: map[string]string{testhelpers.TestTable1Name: "/nonexistent/test_table_1.csv"},
+	}
+
+	_, err := t.verifier.VerifyOnce()
+	t.Require().NotNil(err)
+
+	var fingerprintErr ghostferry.FingerprintError
+	t.Require().True(errors.As(err, &fingerprintErr))
+	t.Require().Equal("target", fingerprintErr.Side)
+	t.Require().Equal(testhelpers.TestSchemaName, fingerprintErr.Schema)
+	t.Require().Equal(testhelpers.TestTable1Name, fingerprintErr.Table)
+	t.Require().Equal(uint64(42), fingerprintErr.MinPaginationKey)
+	t.Require().Equal(uint64(43), fingerprintErr.MaxPaginationKey)
+	t.Require().Contains(err.Error(), "failed to fingerprint 2 rows of `gftest`.`test_table_1` on the target with paginationKeys 42 to 43")
+}
+
+func (t *IterativeVerifierTestSuite) TestFingerprintErrorsOfASharedPoolDescribeTheTarget() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+
+	// The source and the target are the same pool, so that only the side the
+	// queries are run for tells them apart.
+	t.verifier.TargetDB = t.Ferry.SourceDB
+	t.verifier.IgnoredTables = []string{testhelpers.TestCompressedTable1Name}
+	t.verifier.TargetReadHint = "/* side=target */"
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		if strings.Contains(query, "/* side=target */") {
+			query = strings.Replace(query, "`test_table_1`", "`missing_table`", 1)
+		}
+		return query, args
+	}
+	t.Require().Nil(t.verifier.Initialize())
+
+	_, err := t.verifier.VerifyOnce()
+	t.Require().NotNil(err)
+
+	var fingerprintErr ghostferry.FingerprintError
+	t.Require().True(errors.As(err, &fingerprintErr))
+	t.Require().Equal("target", fingerprintErr.Side)
+	t.Require().Equal(testhelpers.TestTable1Name, fingerprintErr.Table)
+	t.Require().Contains(err.Error(), "on the target with paginationKeys 42 to 43")
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithReadHints() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(43, "bar", t.Ferry.TargetDB)
+
+	var queries []string
+	var queriesMutex sync.Mutex
+	t.verifier.SourceReadHint = "/*+ MAX_EXECUTION_TIME(60000) */"
+	t.verifier.TargetReadHint = "/* route=replica */"
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		queriesMutex.Lock()
+		defer queriesMutex.Unlock()
+		queries = append(queries, query)
+		return query, args
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	t.Require().NotEmpty(queries)
+	for _, query := range queries {
+		t.Require().Regexp(`^SELECT (/\*\+ MAX_EXECUTION_TIME\(60000\) \*/|/\* route=replica \*/) `, query)
+	}
+}
+
+func (t *IterativeVerifierTestSuite) TestRecordsFingerprints() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	records := make([]ghostferry.FingerprintRecord, 0)
+	recordsMutex := &sync.Mutex{}
+	t.verifier.IgnoredTables = []string{testhelpers.TestCompressedTable1Name}
+	t.verifier.BatchChecksumShortCircuit = true
+	t.verifier.SourceReadHint = "/* recorded */"
+	t.verifier.TargetReadHint = "/* recorded */"
+	t.verifier.FingerprintRecorder = func(record ghostferry.FingerprintRecord) error {
+		recordsMutex.Lock()
+		defer recordsMutex.Unlock()
+		records = append(records, record)
+		return nil
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	t.Require().Equal(1, len(records))
+	t.Require().Equal(testhelpers.TestTable1Name, records[0].Table)
+	t.Require().Equal([]uint64{42}, records[0].PaginationKeys)
+	t.Require().Equal([]uint64{42}, records[0].Mismatches)
+	t.Require().NotEqual(records[0].SourceHashes[42], records[0].TargetHashes[42])
+
+	// The queries are recorded as they were run, including the batch
+	// checksums, after the read hints are inserted.
+	t.Require().Equal(4, len(records[0].Queries))
+	for idx, side := range []string{"source", "source", "target", "target"} {
+		query := records[0].Queries[idx]
+		t.Require().Equal(side, query.Side)
+		t.Require().True(strings.HasPrefix(query.Query, "SELECT /* recorded */ "))
+		t.Require().Equal([]string{"42"}, query.Args)
+	}
+}
+
+func (t *IterativeVerifierTestSuite) TestSelfTest() {
+	err := t.verifier.SelfTest(t.Ferry.TargetDB, testhelpers.TestSchemaName)
+	t.Require().Nil(err)
+
+	var tables int
+	row := t.Ferry.TargetDB.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE TABLE_SCHEMA = ? AND TABLE_NAME LIKE '_ghostferry_self_test_%'", testhelpers.TestSchemaName)
+	t.Require().Nil(row.Scan(&tables))
+	t.Require().Equal(0, tables)
+
+	t.verifier.DisablePreparedStatements = true
+	err = t.verifier.SelfTest(t.Ferry.TargetDB, testhelpers.TestSchemaName)
+	t.Require().Nil(err)
 }
 
 func (t *IterativeVerifierTestSuite) TestChangingDataChangesHash() {
@@ -228,6 +3020,86 @@ func (t *IterativeVerifierTestSuite) TestDeduplicatesHashes() {
 	t.Require().Equal(1, len(hashes))
 }
 
+func (t *IterativeVerifierTestSuite) TestGetHashesWithReadIsolationLevel() {
+	t.InsertRow(42, "foo")
+	expected := t.GetHashes([]uint64{42})[0]
+
+	t.verifier.ReadIsolationLevel = sqlorig.LevelReadCommitted
+	actual := t.GetHashes([]uint64{42})[0]
+	t.Require().Equal(expected, actual)
+
+	// The fingerprint query is replaced by one returning the isolation
+	// level of its transaction as the fingerprint.
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		return "SELECT `id`, @@transaction_isolation FROM `gftest`.`test_table_1` WHERE `id` IN (?)", args
+	}
+	hashes, err := t.verifier.GetHashes(t.db, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, []uint64{42})
+	t.Require().Nil(err)
+	t.Require().Equal("READ-COMMITTED", string(hashes[42]))
+
+	t.verifier.ReadIsolationLevel = sqlorig.LevelRepeatableRead
+	hashes, err = t.verifier.GetHashes(t.db, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, []uint64{42})
+	t.Require().Nil(err)
+	t.Require().Equal("REPEATABLE-READ", string(hashes[42]))
+	t.verifier.QueryRewriter = nil
+
+	// The MySQL driver rejects isolation levels it does not support, which
+	// shows that the level is applied to the fingerprint query.
+	t.verifier.ReadIsolationLevel = sqlorig.LevelLinearizable
+	_, err = t.verifier.GetHashes(t.db, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, []uint64{42})
+	t.Require().NotNil(err)
+}
+
+func (t *IterativeVerifierTestSuite) TestGetHashesWithoutPreparedStatements() {
+	t.InsertRow(42, "foo")
+	t.InsertRow(43, "bar?")
+	expected := t.GetHashes([]uint64{42, 43})
+
+	t.verifier.DisablePreparedStatements = true
+	hashes, err := t.verifier.GetHashes(t.db, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, []uint64{42, 43})
+	t.Require().Nil(err)
+	t.Require().Equal(expected, []string{string(hashes[42]), string(hashes[43])})
+
+	checksum, err := t.verifier.GetWindowChecksum(t.db, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, ghostferry.FingerprintOptions{NullEquivalentValues: map[string]string{"data": "it's?"}}, 41, 43)
+	t.Require().Nil(err)
+	t.Require().Equal(uint64(2), checksum.RowCount)
+}
+
+func (t *IterativeVerifierTestSuite) TestGetHashesWithoutPreparedStatementsSkipsBackslashEscapedQuotes() {
+	t.InsertRow(42, "foo")
+
+	// The backslash escapes the quote without NO_BACKSLASH_ESCAPES.
+	backslashConfig := *t.Ferry.Config.Source
+	backslashConfig.Params = map[string]string{}
+	for param, value := range t.Ferry.Config.Source.Params {
+		backslashConfig.Params[param] = value
+	}
+	backslashConfig.Params["sql_mode"] = "'STRICT_ALL_TABLES'"
+	backslashDB, err := backslashConfig.SqlDB(nil)
+	t.Require().Nil(err)
+	defer backslashDB.Close()
+
+	t.verifier.DisablePreparedStatements = true
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		return "SELECT `id`, 'it\\'s?' FROM `gftest`.`test_table_1` WHERE `id` IN (?)", args
+	}
+
+	hashes, err := t.verifier.GetHashes(backslashDB, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, []uint64{42})
+	t.Require().Nil(err)
+	t.Require().Equal("it's?", string(hashes[42]))
+}
+
+func (t *IterativeVerifierTestSuite) TestGetHashesSplitsLargeInClauses() {
+	t.InsertRow(42, "foo")
+	t.InsertRow(43, "bar")
+	t.InsertRow(44, "baz")
+	expected := t.GetHashes([]uint64{42, 43, 44})
+
+	t.verifier.MaxInClauseSize = 2
+	actual := t.GetHashes([]uint64{42, 43, 44})
+	t.Require().Equal(expected, actual)
+}
+
 func (t *IterativeVerifierTestSuite) TestDoesntReturnHashIfRecordDoesntExist() {
 	hashes, err := t.verifier.GetHashes(t.db, t.table.Schema, t.table.Name, t.table.GetPaginationColumn().Name, t.table.Columns, []uint64{42, 42})
 	t.Require().Nil(err)
@@ -288,6 +3160,261 @@ func (t *IterativeVerifierTestSuite) TestChangingNumberValueChangesHash() {
 	t.Require().NotEqual(neg, pos)
 }
 
+func (t *IterativeVerifierTestSuite) TestBinaryValuesWithTrailingBytes() {
+	for _, columnType := range []string{"varbinary(16)", "binary(16)"} {
+		t.SetColumnType(testhelpers.TestSchemaName, testhelpers.TestTable1Name, "data", columnType, t.db)
+		t.reloadTables()
+
+		_, err := t.db.Exec("INSERT INTO gftest.test_table_1 VALUES (42, X'666F6F')")
+		t.Require().Nil(err)
+		plain := t.GetHashes([]uint64{42})[0]
+
+		_, err = t.db.Exec("UPDATE gftest.test_table_1 SET data = X'666F6F20' WHERE id = 42")
+		t.Require().Nil(err)
+		space := t.GetHashes([]uint64{42})[0]
+
+		_, err = t.db.Exec("UPDATE gftest.test_table_1 SET data = X'666F6F00' WHERE id = 42")
+		t.Require().Nil(err)
+		zero := t.GetHashes([]uint64{42})[0]
+
+		t.Require().NotEqual(space, zero, columnType)
+		if columnType == "varbinary(16)" {
+			t.Require().NotEqual(plain, space, columnType)
+			t.Require().NotEqual(plain, zero, columnType)
+		} else {
+			// BINARY pads values with 0x00 to the column length.
+			t.Require().Equal(plain, zero, columnType)
+		}
+
+		t.DeleteRow(42)
+	}
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithCaseInsensitiveColumns() {
+	t.InsertRowInDb(42, "Foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "foo", t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	t.verifier.CaseInsensitiveColumns = map[string]map[string]struct{}{
+		testhelpers.TestTable1Name: map[string]struct{}{"data": struct{}{}},
+	}
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	t.UpdateRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithTargetMysqlCompressedColumns() {
+	_, err := t.Ferry.TargetDB.Exec("ALTER TABLE gftest.test_table_1 MODIFY data BLOB")
+	t.Require().Nil(err)
+
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 (id, data) VALUES (42, COMPRESS('foo'))")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+
+	t.verifier.TargetMysqlCompressedColumns = map[string]map[string]struct{}{
+		testhelpers.TestTable1Name: map[string]struct{}{"data": struct{}{}},
+	}
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	_, err = t.Ferry.TargetDB.Exec("UPDATE gftest.test_table_1 SET data = COMPRESS('bar') WHERE id = 42")
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceWithColumnSeverities() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	t.verifier.ColumnSeverities = map[string]map[string]ghostferry.MismatchSeverity{
+		testhelpers.TestTable1Name: map[string]ghostferry.MismatchSeverity{"data": ghostferry.MismatchSeverityLow},
+	}
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal(1, len(result.Mismatches))
+	t.Require().Equal(ghostferry.MismatchSeverityLow, result.Mismatches[0].Severity)
+	t.Require().Equal([]string{"data"}, result.Mismatches[0].Columns)
+
+	t.verifier.MinimumFailingSeverity = ghostferry.MismatchSeverityMedium
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	// Missing rows are always of high severity.
+	t.InsertRowInDb(43, "foo", t.Ferry.SourceDB)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal(ghostferry.MismatchSeverityHigh, result.Mismatches[0].Severity)
+	t.Require().Equal(uint64(43), result.Mismatches[0].PaginationKey)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceReportsDivergenceOffsets() {
+	t.InsertRowInDb(42, "hello world", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "hello wurld", t.Ferry.TargetDB)
+
+	t.verifier.ReportDivergenceOffsets = true
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"data"}, result.Mismatches[0].Columns)
+	t.Require().Equal(map[string]int{"data": 7}, result.Mismatches[0].DivergenceOffsets)
+
+	t.UpdateRowInDb(42, "hello", t.Ferry.TargetDB)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().Equal(map[string]int{"data": 5}, result.Mismatches[0].DivergenceOffsets)
+
+	_, err = t.Ferry.TargetDB.Exec("UPDATE gftest.test_table_1 SET data = NULL WHERE id = 42")
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().Equal(map[string]int{"data": 0}, result.Mismatches[0].DivergenceOffsets)
+}
+
+func (t *IterativeVerifierTestSuite) TestDivergenceOffsetsSplitLargeInClauses() {
+	for id := 42; id < 45; id++ {
+		t.InsertRowInDb(id, "hello world", t.Ferry.SourceDB)
+		t.InsertRowInDb(id, "hello wurld", t.Ferry.TargetDB)
+	}
+
+	t.verifier.ReportDivergenceOffsets = true
+	t.verifier.MaxInClauseSize = 2
+
+	var mutex sync.Mutex
+	var largestInClause int
+	t.verifier.QueryRewriter = func(query string, args []interface{}) (string, []interface{}) {
+		if strings.Contains(query, " IN (") {
+			mutex.Lock()
+			if len(args) > largestInClause {
+				largestInClause = len(args)
+			}
+			mutex.Unlock()
+		}
+		return query, args
+	}
+
+	// The mismatched rows are reverified during cutover as a single batch.
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal(3, len(result.Mismatches))
+	for _, mismatch := range result.Mismatches {
+		t.Require().Equal(map[string]int{"data": 7}, mismatch.DivergenceOffsets)
+	}
+	t.Require().Equal(2, largestInClause)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceReportsDivergenceOffsetsOfLargeUnsignedValues() {
+	t.addExternalIdColumn()
+
+	_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.test_table_1 (id, data, external_id) VALUES (42, 'foo', 18446744073709551615)")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 (id, data, external_id) VALUES (42, 'foo', 18446744073709551614)")
+	t.Require().Nil(err)
+
+	t.verifier.ReportDivergenceOffsets = true
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"external_id"}, result.Mismatches[0].Columns)
+	t.Require().Equal(map[string]int{"external_id": 19}, result.Mismatches[0].DivergenceOffsets)
+}
+
+func (t *IterativeVerifierTestSuite) TestColumnSeveritiesCompareNullValues() {
+	t.addExternalIdColumn()
+	t.verifier.ColumnSeverities = map[string]map[string]ghostferry.MismatchSeverity{
+		testhelpers.TestTable1Name: map[string]ghostferry.MismatchSeverity{"data": ghostferry.MismatchSeverityLow},
+	}
+
+	// The data is NULL on both sides.
+	_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, NULL, 1)")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, NULL, 2)")
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"external_id"}, result.Mismatches[0].Columns)
+
+	// The data is NULL on the source only.
+	_, err = t.Ferry.TargetDB.Exec("UPDATE gftest.test_table_1 SET data = 'NULL' WHERE id = 42")
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal([]string{"data", "external_id"}, result.Mismatches[0].Columns)
+}
+
+func (t *IterativeVerifierTestSuite) TestVerifyOnceDistinguishesNullFromNullString() {
+	_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, NULL), (43, NULL)")
+	t.Require().Nil(err)
+	_, err = t.Ferry.TargetDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, 'NULL'), (43, NULL)")
+	t.Require().Nil(err)
+
+	// The default format fingerprints NULL as the string 'NULL'.
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	t.verifier.FingerprintFormat = ghostferry.FingerprintFormatV2
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+	t.Require().Equal("verification failed on table: gftest.test_table_1 for paginationKey: 42", result.Message)
+	t.Require().Equal([]string{"data"}, result.Mismatches[0].Columns)
+}
+
+func (t *IterativeVerifierTestSuite) TestColumnSeveritiesDuringCutover() {
+	t.InsertRowInDb(42, "foo", t.Ferry.SourceDB)
+	t.InsertRowInDb(42, "bar", t.Ferry.TargetDB)
+
+	t.verifier.ColumnSeverities = map[string]map[string]ghostferry.MismatchSeverity{
+		testhelpers.TestTable1Name: map[string]ghostferry.MismatchSeverity{"data": ghostferry.MismatchSeverityMedium},
+	}
+	t.verifier.MinimumFailingSeverity = ghostferry.MismatchSeverityHigh
+
+	err := t.verifier.VerifyBeforeCutover()
+	t.Require().Nil(err)
+
+	result, err := t.verifier.VerifyDuringCutover()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+}
+
 func (t *IterativeVerifierTestSuite) TestNULLValues() {
 	_, err := t.db.Exec("INSERT INTO gftest.test_table_1 VALUES (42, NULL)")
 	t.Require().Nil(err)
@@ -304,6 +3431,27 @@ func (t *IterativeVerifierTestSuite) TestNULLValues() {
 	t.Require().NotEqual(foo, null)
 }
 
+func (t *IterativeVerifierTestSuite) TestNullEquivalentValues() {
+	t.verifier.NullEquivalentValues = map[string]map[string]string{
+		testhelpers.TestTable1Name: map[string]string{"data": ""},
+	}
+
+	_, err := t.Ferry.SourceDB.Exec("INSERT INTO gftest.test_table_1 VALUES (42, NULL)")
+	t.Require().Nil(err)
+	t.InsertRowInDb(42, "", t.Ferry.TargetDB)
+
+	result, err := t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().True(result.DataCorrect)
+
+	_, err = t.Ferry.TargetDB.Exec("UPDATE gftest.test_table_1 SET data = 'foo' WHERE id = 42")
+	t.Require().Nil(err)
+
+	result, err = t.verifier.VerifyOnce()
+	t.Require().Nil(err)
+	t.Require().False(result.DataCorrect)
+}
+
 func (t *IterativeVerifierTestSuite) InsertRow(id int, data string) {
 	t.InsertRowInDb(id, data, t.db)
 }
@@ -364,6 +3512,14 @@ func (t *IterativeVerifierTestSuite) GetHashes(ids []uint64) []string {
 	return res
 }
 
+func (t *IterativeVerifierTestSuite) addExternalIdColumn() {
+	for _, db := range []*sql.DB{t.Ferry.SourceDB, t.Ferry.TargetDB} {
+		_, err := db.Exec("ALTER TABLE gftest.test_table_1 ADD COLUMN external_id bigint(20) unsigned")
+		t.Require().Nil(err)
+	}
+	t.reloadTables()
+}
+
 func (t *IterativeVerifierTestSuite) reloadTables() {
 	tableFilter := &testhelpers.TestTableFilter{
 		DbsFunc:    testhelpers.DbApplicabilityFilter([]string{testhelpers.TestSchemaName}),
@@ -381,6 +3537,44 @@ func (t *IterativeVerifierTestSuite) reloadTables() {
 	t.Require().NotNil(t.table)
 }
 
+type recordingSpanKey struct{}
+
+type recordingSpan struct {
+	name       string
+	parent     *recordingSpan
+	attributes map[string]interface{}
+	ended      bool
+	mutex      sync.Mutex
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attributes[key] = value
+}
+
+func (s *recordingSpan) End() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ended = true
+}
+
+type recordingTracer struct {
+	spans []*recordingSpan
+	mutex sync.Mutex
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, ghostferry.VerificationSpan) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	parent, _ := ctx.Value(recordingSpanKey{}).(*recordingSpan)
+	span := &recordingSpan{name: name, parent: parent, attributes: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+
+	return context.WithValue(ctx, recordingSpanKey{}, span), span
+}
+
 type ReverifyStoreTestSuite struct {
 	suite.Suite
 
@@ -412,6 +3606,60 @@ func (t *ReverifyStoreTestSuite) TestAddEntryIntoReverifyStoreWillDeduplicate()
 	)
 }
 
+func (t *ReverifyStoreTestSuite) TestLogsWithLogTag() {
+	hook := &entriesHook{}
+	logger := logrus.StandardLogger()
+	oldHooks, oldLevel := logger.Hooks, logger.Level
+	logger.Hooks = make(logrus.LevelHooks)
+	logger.Hooks.Add(hook)
+	logger.SetLevel(logrus.DebugLevel)
+	defer func() {
+		logger.Hooks = oldHooks
+		logger.SetLevel(oldLevel)
+	}()
+
+	t.store.EmitLogPerRowCount = 1
+	t.store.LogTag = "tenant_1.reverify_store"
+
+	table1 := &ghostferry.TableSchema{Table: &schema.Table{Schema: "gftest", Name: "table1"}}
+	t.store.Add(ghostferry.ReverifyEntry{PaginationKey: 100, Table: table1})
+
+	t.Require().Equal(1, len(hook.entries))
+	t.Require().Equal("tenant_1.reverify_store", hook.entries[0].Data["tag"])
+}
+
+func (t *ReverifyStoreTestSuite) TestCountsByTable() {
+	table1 := &ghostferry.TableSchema{Table: &schema.Table{Schema: "gftest", Name: "table1"}}
+	table2 := &ghostferry.TableSchema{Table: &schema.Table{Schema: "gftest", Name: "table2"}}
+	t.store.Add(ghostferry.ReverifyEntry{PaginationKey: 100, Table: table1})
+	t.store.Add(ghostferry.ReverifyEntry{PaginationKey: 101, Table: table1})
+	t.store.Add(ghostferry.ReverifyEntry{PaginationKey: 101, Table: table1})
+	t.store.Add(ghostferry.ReverifyEntry{PaginationKey: 100, Table: table2})
+
+	t.Require().Equal(map[ghostferry.TableIdentifier]int{
+		ghostferry.TableIdentifier{SchemaName: "gftest", TableName: "table1"}: 2,
+		ghostferry.TableIdentifier{SchemaName: "gftest", TableName: "table2"}: 1,
+	}, t.store.CountsByTable())
+
+	t.store.FlushAndBatchByTable(10)
+	t.Require().Equal(map[ghostferry.TableIdentifier]int{}, t.store.CountsByTable())
+}
+
+func (t *ReverifyStoreTestSuite) TestCountsByOrigin() {
+	table1 := &ghostferry.TableSchema{Table: &schema.Table{Schema: "gftest", Name: "table1"}}
+	t.store.Add(ghostferry.ReverifyEntry{PaginationKey: 100, Table: table1, Origin: ghostferry.ReverifyOriginScan})
+	t.store.Add(ghostferry.ReverifyEntry{PaginationKey: 100, Table: table1, Origin: ghostferry.ReverifyOriginBinlog})
+	t.store.Add(ghostferry.ReverifyEntry{PaginationKey: 101, Table: table1, Origin: ghostferry.ReverifyOriginBinlog})
+
+	t.store.FlushAndBatchByTable(10)
+	t.store.Add(ghostferry.ReverifyEntry{PaginationKey: 100, Table: table1, Origin: ghostferry.ReverifyOriginBinlog})
+
+	t.Require().Equal(map[ghostferry.ReverifyOrigin]uint64{
+		ghostferry.ReverifyOriginScan:   1,
+		ghostferry.ReverifyOriginBinlog: 2,
+	}, t.store.CountsByOrigin())
+}
+
 func (t *ReverifyStoreTestSuite) TestFlushAndBatchByTableWillCreateReverifyBatchesAndClearTheMapStore() {
 	expectedTable1PaginationKeys := make([]uint64, 0, 55)
 	table1 := &ghostferry.TableSchema{Table: &schema.Table{Schema: "gftest", Name: "table1"}}
@@ -467,6 +3715,113 @@ func (t *ReverifyStoreTestSuite) TestFlushAndBatchByTableWillCreateReverifyBatch
 	t.Require().Equal(0, len(t.store.MapStore))
 }
 
+// Verifies a table whose paginationKeys are spread across a range many
+// orders of magnitude larger than its number of rows. As the cursor resumes
+// after the last paginationKey it has seen rather than iterating over fixed
+// ranges of paginationKeys, the number of queries only depends on the number
+// of rows.
+func BenchmarkVerifyOnceWithSparsePaginationKeys(b *testing.B) {
+	const rowCount = 1000
+	const paginationKeyGap = 1 << 50
+
+	verifier, teardown := setupBenchmarkVerifier(rowCount, func(id uint64) (uint64, string) {
+		return id * paginationKeyGap, fmt.Sprintf("row %d", id)
+	})
+	defer teardown()
+
+	benchmarkVerifyOnce(b, verifier)
+}
+
+// Compares the throughput of the hash functions on rows wide enough for the
+// cost of hashing to dominate.
+func BenchmarkVerifyOnceWithHashFunctions(b *testing.B) {
+	const rowCount = 1000
+
+	verifier, teardown := setupBenchmarkVerifier(rowCount, func(id uint64) (uint64, string) {
+		return id, strings.Repeat(fmt.Sprintf("row %d ", id), 2000)
+	})
+	defer teardown()
+
+	for _, hashFunction := range []ghostferry.FingerprintHashFunction{ghostferry.FingerprintHashMD5, ghostferry.FingerprintHashCRC32} {
+		b.Run(string(hashFunction), func(b *testing.B) {
+			verifier.FingerprintHashFunction = hashFunction
+			benchmarkVerifyOnce(b, verifier)
+		})
+	}
+}
+
+// Seeds the source and the target with the same rowCount rows, whose id and
+// data are returned by row, and returns a verifier of the tables.
+func setupBenchmarkVerifier(rowCount uint64, row func(uint64) (uint64, string)) (*ghostferry.IterativeVerifier, func()) {
+	testhelpers.SetupTest()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	testFerry := testhelpers.NewTestFerry()
+	testhelpers.PanicIfError(testFerry.Initialize())
+	ferry := testFerry.Ferry
+
+	for _, db := range []*sql.DB{ferry.SourceDB, ferry.TargetDB} {
+		_, err := db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", testhelpers.TestSchemaName))
+		testhelpers.PanicIfError(err)
+
+		testhelpers.SeedInitialData(db, testhelpers.TestSchemaName, testhelpers.TestTable1Name, 0)
+		_, err = db.Exec("ALTER TABLE gftest.test_table_1 MODIFY data TEXT")
+		testhelpers.PanicIfError(err)
+
+		for id := uint64(1); id <= rowCount; id++ {
+			paginationKey, data := row(id)
+			_, err := db.Exec("INSERT INTO gftest.test_table_1 VALUES (?, ?)", paginationKey, data)
+			testhelpers.PanicIfError(err)
+		}
+	}
+
+	teardown := func() {
+		for _, db := range []*sql.DB{ferry.SourceDB, ferry.TargetDB} {
+			db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", testhelpers.TestSchemaName))
+		}
+	}
+
+	tableFilter := &testhelpers.TestTableFilter{
+		DbsFunc:    testhelpers.DbApplicabilityFilter([]string{testhelpers.TestSchemaName}),
+		TablesFunc: nil,
+	}
+
+	tables, err := ghostferry.LoadTables(ferry.SourceDB, tableFilter, nil, nil, nil)
+	testhelpers.PanicIfError(err)
+
+	verifier := &ghostferry.IterativeVerifier{
+		CursorConfig: &ghostferry.CursorConfig{
+			DB:          ferry.SourceDB,
+			BatchSize:   ferry.Config.DataIterationBatchSize,
+			ReadRetries: ferry.Config.DBReadRetries,
+		},
+		BinlogStreamer:   ferry.BinlogStreamer,
+		SourceDB:         ferry.SourceDB,
+		TargetDB:         ferry.TargetDB,
+		Tables:           tables.AsSlice(),
+		TableSchemaCache: tables,
+
+		Concurrency: 1,
+	}
+	testhelpers.PanicIfError(verifier.Initialize())
+
+	return verifier, teardown
+}
+
+func benchmarkVerifyOnce(b *testing.B, verifier *ghostferry.IterativeVerifier) {
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		result, err := verifier.VerifyOnce()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if !result.DataCorrect {
+			b.Fatal(result.Message)
+		}
+	}
+}
+
 func TestIterativeVerifierTestSuite(t *testing.T) {
 	testhelpers.SetupTest()
 	suite.Run(t, &IterativeVerifierTestSuite{GhostferryUnitTestSuite: &testhelpers.GhostferryUnitTestSuite{}})
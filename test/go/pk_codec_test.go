@@ -0,0 +1,59 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Shopify/ghostferry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegerPKCodec(t *testing.T) {
+	codec := ghostferry.IntegerPKCodec{}
+
+	encoded, err := codec.EncodePK(uint64(math.MaxUint64))
+	assert.Nil(t, err)
+	assert.Equal(t, "18446744073709551615", encoded)
+
+	decoded, err := codec.DecodePK(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), decoded)
+
+	_, err = codec.EncodePK("42")
+	assert.EqualError(t, err, "cannot encode key of type string as an integer")
+
+	_, err = codec.DecodePK("-1")
+	assert.NotNil(t, err)
+}
+
+func TestReverifyStorePersistsKeysWithItsPKCodec(t *testing.T) {
+	store := ghostferry.NewReverifyStore()
+	table := ghostferry.TableIdentifier{SchemaName: "gftest", TableName: "table1"}
+
+	persisted, err := store.EncodeBatches([]ghostferry.ReverifyBatch{
+		{PaginationKeys: []uint64{1, math.MaxUint64}, Table: table},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []ghostferry.PersistedReverifyBatch{
+		{Table: table, Keys: []string{"1", "18446744073709551615"}},
+	}, persisted)
+
+	batch, err := store.DecodeBatch(persisted[0])
+	assert.Nil(t, err)
+	assert.Equal(t, ghostferry.ReverifyBatch{PaginationKeys: []uint64{1, math.MaxUint64}, Table: table}, batch)
+
+	// The store only holds integer paginationKeys.
+	store.PKCodec = stringPKCodec{}
+	_, err = store.DecodeBatch(persisted[0])
+	assert.EqualError(t, err, "key 1 of table gftest.table1 decodes to string, not a paginationKey")
+}
+
+type stringPKCodec struct{}
+
+func (stringPKCodec) EncodePK(key interface{}) (string, error) {
+	return key.(string), nil
+}
+
+func (stringPKCodec) DecodePK(encoded string) (interface{}, error) {
+	return encoded, nil
+}
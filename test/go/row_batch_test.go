@@ -73,6 +73,19 @@ func (this *RowBatchTestSuite) TestRowBatchGeneratesInsertQuery() {
 	this.Require().Equal(expected, v1)
 }
 
+func (this *RowBatchTestSuite) TestRowBatchGeneratesUpsertQuery() {
+	vals := []ghostferry.RowData{
+		ghostferry.RowData{1000, []byte("val0"), true},
+		ghostferry.RowData{1001, []byte("val1"), false},
+	}
+	batch := ghostferry.NewRowBatch(this.sourceTable, vals, 0)
+
+	q, v, err := batch.AsUpsertSQLQuery(this.targetTable.Schema, this.targetTable.Name)
+	this.Require().Nil(err)
+	this.Require().Equal("INSERT INTO `target_schema`.`target_table` (`col1`,`col2`,`col3`) VALUES (?,?,?),(?,?,?) ON DUPLICATE KEY UPDATE `col1`=VALUES(`col1`),`col2`=VALUES(`col2`),`col3`=VALUES(`col3`)", q)
+	this.Require().Equal([]interface{}{1000, []byte("val0"), true, 1001, []byte("val1"), false}, v)
+}
+
 func (this *RowBatchTestSuite) TestRowBatchWithWrongColumnsReturnsError() {
 	vals := []ghostferry.RowData{
 		ghostferry.RowData{1000, []byte("val0"), true},
@@ -2,6 +2,7 @@ package test
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -34,6 +35,18 @@ func (this *UtilsTestSuite) TestReturnsErrAsIs() {
 	this.Require().Equal(expected, actual)
 }
 
+func (this *UtilsTestSuite) TestDoesNotRetryErrTargetUnhealthy() {
+	called := 0
+
+	err := ghostferry.WithRetries(5, 0, this.logger, "test", func() error {
+		called++
+		return ghostferry.ErrTargetUnhealthy
+	})
+
+	this.Require().Equal(1, called)
+	this.Require().Equal(ghostferry.ErrTargetUnhealthy, err)
+}
+
 func (this *UtilsTestSuite) TestRespectsMaxRetries() {
 	called := 0
 
@@ -65,6 +78,41 @@ func (this *UtilsTestSuite) Test0UnlimitedRetries() {
 	this.Require().Equal(10, called)
 }
 
+func (this *UtilsTestSuite) TestLogsRetryAttempts() {
+	hook := &entriesHook{}
+	logger := logrus.New()
+	logger.Hooks.Add(hook)
+
+	ghostferry.WithRetries(3, 0, logger.WithField("table", "gftest.table1"), "test", func() error {
+		return fmt.Errorf("test error")
+	})
+
+	this.Require().Equal(3, len(hook.entries))
+	for idx, entry := range hook.entries {
+		this.Require().Equal(idx+1, entry.Data["attempt"])
+		this.Require().Equal(3, entry.Data["max_retries"])
+		this.Require().Equal("gftest.table1", entry.Data["table"])
+		this.Require().Equal("test error", entry.Data[logrus.ErrorKey].(error).Error())
+	}
+}
+
+type entriesHook struct {
+	entries []*logrus.Entry
+	mutex   sync.Mutex
+}
+
+func (h *entriesHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *entriesHook) Fire(entry *logrus.Entry) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.entries = append(h.entries, entry)
+	return nil
+}
+
 func TestUtils(t *testing.T) {
 	testhelpers.SetupTest()
 	suite.Run(t, new(UtilsTestSuite))
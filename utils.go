@@ -36,7 +36,8 @@ func WithRetriesContext(ctx context.Context, maxRetries int, sleep time.Duration
 		}
 
 		err = f()
-		if err == nil || err == context.Canceled {
+		// Retrying cannot succeed once the target is known to be unhealthy.
+		if err == nil || err == context.Canceled || err == ErrTargetUnhealthy {
 			return err
 		}
 
@@ -44,13 +45,19 @@ func WithRetriesContext(ctx context.Context, maxRetries int, sleep time.Duration
 			break
 		}
 
-		logger.WithError(err).Errorf("failed to %s, %d of %d max retries", verb, try, maxRetries)
+		logger.WithError(err).WithFields(logrus.Fields{
+			"attempt":     try,
+			"max_retries": maxRetries,
+		}).Errorf("failed to %s, %d of %d max retries", verb, try, maxRetries)
 
 		try++
 		time.Sleep(sleep)
 	}
 
-	logger.WithError(err).Errorf("failed to %s after %d attempts, retry limit exceeded", verb, try)
+	logger.WithError(err).WithFields(logrus.Fields{
+		"attempt":     try,
+		"max_retries": maxRetries,
+	}).Errorf("failed to %s after %d attempts, retry limit exceeded", verb, try)
 
 	return
 }
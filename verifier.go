@@ -1,7 +1,9 @@
 package ghostferry
 
 import (
+	"crypto/sha256"
 	sqlorig "database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	sql "github.com/Shopify/ghostferry/sqlwrapper"
@@ -21,6 +23,19 @@ type VerificationResult struct {
 	DataCorrect     bool
 	Message         string
 	IncorrectTables []string
+
+	// The individual rows that were found to differ, if the verifier tracks
+	// them. This is sorted by table and paginationKey.
+	Mismatches []VerificationMismatch
+
+	// The rows that differed but were repaired and then found to match, if
+	// the verifier repairs mismatches. These are not in Mismatches.
+	RepairedMismatches []VerificationMismatch
+
+	// The tables of which only a sample of the rows were verified, such as
+	// after exceeding the TableTimeBudget of the IterativeVerifier. The
+	// result only holds for the verified rows of these tables.
+	PartiallyVerifiedTables []string
 }
 
 func (e VerificationResult) Error() string {
@@ -28,7 +43,78 @@ func (e VerificationResult) Error() string {
 }
 
 func NewCorrectVerificationResult() VerificationResult {
-	return VerificationResult{true, "", []string{}}
+	return VerificationResult{DataCorrect: true, IncorrectTables: []string{}}
+}
+
+// A single row that differs between the source and the target.
+type VerificationMismatch struct {
+	Table         TableIdentifier
+	PaginationKey uint64
+
+	// A content-addressed identifier of the mismatch, derived only from the
+	// table and the paginationKey. The same mismatched row has the same
+	// Fingerprint across verification runs, which allows deduplicating
+	// recurring mismatches.
+	Fingerprint string
+
+	// The highest severity of the columns that differ. This is
+	// MismatchSeverityHigh unless the differing columns are known.
+	Severity MismatchSeverity
+
+	// The columns that differ, if the verifier determined them.
+	Columns []string
+
+	// Map of differing column => offset of the first byte that differs
+	// between the source and the target values, if the verifier determined
+	// them.
+	DivergenceOffsets map[string]int
+}
+
+func NewVerificationMismatch(table TableIdentifier, paginationKey uint64) VerificationMismatch {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s,%s,%d", table.SchemaName, table.TableName, paginationKey)))
+
+	return VerificationMismatch{
+		Table:         table,
+		PaginationKey: paginationKey,
+		Fingerprint:   hex.EncodeToString(sum[:]),
+		Severity:      MismatchSeverityHigh,
+	}
+}
+
+// How serious a difference in a column is. Severities are ordered, a higher
+// value is more severe.
+type MismatchSeverity int
+
+const (
+	MismatchSeverityLow MismatchSeverity = iota + 1
+	MismatchSeverityMedium
+	MismatchSeverityHigh
+)
+
+func ParseMismatchSeverity(severity string) (MismatchSeverity, error) {
+	switch severity {
+	case "low":
+		return MismatchSeverityLow, nil
+	case "medium":
+		return MismatchSeverityMedium, nil
+	case "high":
+		return MismatchSeverityHigh, nil
+	default:
+		return 0, fmt.Errorf("unknown mismatch severity: %s", severity)
+	}
+}
+
+func (s MismatchSeverity) String() string {
+	switch s {
+	case MismatchSeverityLow:
+		return "low"
+	case MismatchSeverityMedium:
+		return "medium"
+	case MismatchSeverityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
 }
 
 type VerificationResultAndStatus struct {
@@ -179,9 +265,9 @@ func (v *ChecksumTableVerifier) VerifyDuringCutover() (VerificationResult, error
 		} else {
 			logWithTable.WithFields(logFields).Error("tables on source and target DOES NOT MATCH")
 			return VerificationResult{
-				false,
-				fmt.Sprintf("data on table %s (%s) mismatched", sourceTable, targetTable),
-				[]string{table.String()},
+				DataCorrect:     false,
+				Message:         fmt.Sprintf("data on table %s (%s) mismatched", sourceTable, targetTable),
+				IncorrectTables: []string{table.String()},
 			}, nil
 		}
 	}